@@ -0,0 +1,118 @@
+package ener314
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultSwitchRetryInterval is how often SwitchConfirmed retransmits.
+const DefaultSwitchRetryInterval = 2 * time.Second
+
+// AdapterPlus is a MIHO005 Adapter Plus switched monitor plug.
+type AdapterPlus struct {
+	SensorId uint32
+	// RetryInterval is how often SwitchConfirmed retransmits.
+	RetryInterval time.Duration
+
+	dev           *Device
+	mu            sync.Mutex
+	readings      MonitorReadings
+	on            bool
+	switchUpdated time.Time
+}
+
+// AdapterPlus returns the handle for the MIHO005 Adapter Plus with the given
+// id.
+func (d *Device) AdapterPlus(sensorId uint32) *AdapterPlus {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if a, ok := d.handles[handleKey{adapterProdId, sensorId}].(*AdapterPlus); ok {
+		return a
+	}
+	a := &AdapterPlus{SensorId: sensorId, RetryInterval: DefaultSwitchRetryInterval, dev: d}
+	d.addHandle(handleKey{adapterProdId, sensorId}, a)
+	return a
+}
+
+// Readings returns the last reported readings.
+func (a *AdapterPlus) Readings() MonitorReadings {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.readings
+}
+
+// State returns the last reported switch state and when it was reported.
+func (a *AdapterPlus) State() (bool, time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.on, a.switchUpdated
+}
+
+// Switch sends a command to switch the socket on or off.
+func (a *AdapterPlus) Switch(on bool) error {
+	return a.dev.send(adapterProdId, a.SensorId, ActuateSwitch{on})
+}
+
+// SwitchConfirmed switches the socket on or off, retransmitting every
+// RetryInterval until the socket reports the requested state or timeout
+// elapses. Messages must meanwhile be received by Device.Run, or by calls to
+// Device.Receive, in another goroutine.
+func (a *AdapterPlus) SwitchConfirmed(on bool, timeout time.Duration) error {
+	reports := a.dev.Subscribe(4, ForSensor(a.SensorId), OfType(MessageEvent{}))
+	defer reports.Close()
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	for {
+		err := a.Switch(on)
+		if err != nil {
+			return err
+		}
+		interval := a.RetryInterval
+		if interval <= 0 {
+			interval = DefaultSwitchRetryInterval
+		}
+		retry := time.NewTimer(interval)
+	wait:
+		for {
+			select {
+			case e, ok := <-reports.C:
+				if !ok {
+					retry.Stop()
+					return ErrTimeout
+				}
+				if reportsSwitch(e.(MessageEvent).Message, on) {
+					retry.Stop()
+					return nil
+				}
+			case <-retry.C:
+				break wait
+			case <-deadline.C:
+				retry.Stop()
+				return ErrTimeout
+			}
+		}
+	}
+}
+
+// reportsSwitch reports whether msg reports the switch state on.
+func reportsSwitch(msg *Message, on bool) bool {
+	for _, record := range msg.Records {
+		if s, ok := record.(SwitchState); ok && s.On == on {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *AdapterPlus) update(msg *Message, now time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, record := range msg.Records {
+		if s, ok := record.(SwitchState); ok {
+			a.on = s.On
+			a.switchUpdated = now
+			continue
+		}
+		a.readings.update(record, now)
+	}
+}
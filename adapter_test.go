@@ -0,0 +1,85 @@
+package ener314
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdapterPlusUpdate(t *testing.T) {
+	dev := NewDevice()
+	adapter := dev.AdapterPlus(0x000456)
+	assert.Same(t, adapter, dev.AdapterPlus(0x000456))
+
+	now := time.Now()
+	adapter.update(&Message{
+		ManuId: energenieManuId, ProdId: adapterProdId, SensorId: 0x000456,
+		Records: []Record{SwitchState{true}, RealPower{60}, Voltage{239}},
+	}, now)
+
+	on, updated := adapter.State()
+	assert.True(t, on)
+	assert.Equal(t, now, updated)
+	assert.Equal(t, 60.0, adapter.Readings().RealPower)
+	assert.Equal(t, 239.0, adapter.Readings().Voltage)
+}
+
+func switchStateFrame(sensorId uint32, on bool, pip uint16) []byte {
+	message := &Message{
+		ManuId: energenieManuId, ProdId: adapterProdId, SensorId: sensorId,
+		Records: []Record{SwitchState{on}},
+	}
+	return Encode(message, NewSequencePipSource(pip))
+}
+
+// runAdapter runs a Device with a fakeRadio and an AdapterPlus retransmitting
+// every 10ms.
+func runAdapter(t *testing.T) (*fakeRadio, *AdapterPlus) {
+	radio := &fakeRadio{}
+	dev := NewDevice()
+	dev.Radio = radio
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- dev.Run(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	adapter := dev.AdapterPlus(0x000456)
+	adapter.RetryInterval = 10 * time.Millisecond
+	return radio, adapter
+}
+
+func TestSwitchConfirmedRetransmits(t *testing.T) {
+	radio, adapter := runAdapter(t)
+	assert.Equal(t, ErrTimeout, adapter.SwitchConfirmed(true, 100*time.Millisecond))
+	assert.True(t, len(radio.sentFrames()) >= 3, "sent %d frames", len(radio.sentFrames()))
+}
+
+func TestSwitchConfirmed(t *testing.T) {
+	radio, adapter := runAdapter(t)
+	go func() {
+		for len(radio.sentFrames()) < 2 {
+			time.Sleep(time.Millisecond)
+		}
+		radio.inject(switchStateFrame(0x000456, true, 1))
+	}()
+	assert.NoError(t, adapter.SwitchConfirmed(true, 5*time.Second))
+	on, _ := adapter.State()
+	assert.True(t, on)
+}
+
+func TestSwitchConfirmedTimeout(t *testing.T) {
+	radio, adapter := runAdapter(t)
+	go func() {
+		for len(radio.sentFrames()) < 1 {
+			time.Sleep(time.Millisecond)
+		}
+		radio.inject(switchStateFrame(0x000456, false, 1), switchStateFrame(0x000789, true, 2))
+	}()
+	assert.Equal(t, ErrTimeout, adapter.SwitchConfirmed(true, 300*time.Millisecond))
+}
@@ -0,0 +1,75 @@
+package ener314
+
+// MessageBuilder builds a Message to send to a device. It defaults to an
+// Energenie eTRV.
+type MessageBuilder struct {
+	message Message
+	err     error
+}
+
+func NewMessageBuilder() *MessageBuilder {
+	return &MessageBuilder{message: Message{ManuId: energenieManuId, ProdId: eTRVProdId}}
+}
+
+func (b *MessageBuilder) Manufacturer(manuId byte) *MessageBuilder {
+	b.message.ManuId = manuId
+	return b
+}
+
+func (b *MessageBuilder) Product(prodId byte) *MessageBuilder {
+	b.message.ProdId = prodId
+	return b
+}
+
+func (b *MessageBuilder) Sensor(sensorId uint32) *MessageBuilder {
+	b.message.SensorId = sensorId
+	return b
+}
+
+// Encryption overrides the encryption id registered for the product.
+func (b *MessageBuilder) Encryption(encryptionId byte) *MessageBuilder {
+	b.message.EncryptionId = encryptionId
+	return b
+}
+
+// validator is implemented by records whose value may not fit in a record.
+type validator interface {
+	validate() error
+}
+
+// Record appends records to the message. Any error is returned from Build.
+func (b *MessageBuilder) Record(records ...Record) *MessageBuilder {
+	for _, record := range records {
+		if v, ok := record.(validator); ok {
+			b.fail(v.validate())
+		}
+	}
+	b.message.Records = append(b.message.Records, records...)
+	return b
+}
+
+// Param appends the record registered for paramId with the given value, see
+// NewRecord. Any error is returned from Build.
+func (b *MessageBuilder) Param(paramId byte, value interface{}) *MessageBuilder {
+	record, err := NewRecord(b.message.ManuId, b.message.ProdId, paramId, value)
+	if err != nil {
+		b.fail(err)
+		return b
+	}
+	return b.Record(record)
+}
+
+func (b *MessageBuilder) fail(err error) {
+	if b.err == nil {
+		b.err = err
+	}
+}
+
+func (b *MessageBuilder) Build() (*Message, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	message := b.message
+	message.Records = append([]Record(nil), b.message.Records...)
+	return &message, nil
+}
@@ -0,0 +1,29 @@
+package ener314
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessageBuilder(t *testing.T) {
+	message, err := NewMessageBuilder().
+		Manufacturer(energenieManuId).
+		Product(eTRVProdId).
+		Sensor(0x12097f).
+		Param(OT_TEMP_SET, 19.5).
+		Record(NewUnhandledRecord(0x5e, UintValue(7))).
+		Build()
+	assert.NoError(t, err)
+
+	data := encodeMessage(message)
+	decoded, err := decodePacket(data)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(0x12097f), decoded.SensorId)
+	assert.Equal(t, []Record{SetTemperature{19.5}, UnhandledRecord{0x5e, 0x01, []byte{0x07}}}, decoded.Records)
+}
+
+func TestMessageBuilderError(t *testing.T) {
+	_, err := NewMessageBuilder().Sensor(0x00097f).Param(0x5e, 1).Build()
+	assert.Equal(t, ErrNoEncoder, err)
+}
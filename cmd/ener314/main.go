@@ -1,9 +1,12 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
 	"log"
-	"time"
+	"os"
+	"os/signal"
 
 	"github.com/barnybug/ener314"
 )
@@ -15,42 +18,89 @@ func fatalIfErr(err error) {
 }
 
 func main() {
+	pair := flag.Duration("pair", 0, "accept joins from any device for this long")
+	registry := flag.String("registry", "", "file to keep the registry of known devices in")
+	flag.Parse()
+
 	ener314.SetLevel(ener314.LOG_TRACE)
 	dev := ener314.NewDevice()
+	dev.RegistryFile = *registry
+	dev.TracePackets = true
 	err := dev.Start()
 	fatalIfErr(err)
 
-	log.Printf("Device temperature (approx): %dC", dev.GetTemperature())
+	if *pair > 0 {
+		dev.OpenPairing(*pair)
+	}
 
-	for {
-		// poll receive
-		msg := dev.Receive()
-		if msg == nil {
-			time.Sleep(100 * time.Millisecond)
-			continue
-		}
+	if temp, err := dev.GetTemperature(); err == nil {
+		log.Printf("Device temperature (approx): %dC", temp)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	go func() {
+		<-interrupt
+		cancel()
+	}()
+
+	events := dev.Subscribe(100)
+	go dev.Run(ctx)
 
-		for _, record := range msg.Records {
-			switch t := record.(type) {
-			case ener314.Join:
-				log.Printf("%06x Join\n", msg.SensorId)
-				dev.Join(msg.SensorId)
-			case ener314.Temperature:
-				log.Printf("%06x Temperature: %.2f°C\n", msg.SensorId, t.Value)
-				// dev.TargetTemperature(msg.SensorId, 10)
-				// dev.Voltage(msg.SensorId)
-				// dev.Diagnostics(msg.SensorId)
-				// dev.SetValveState(msg.SensorId, ener314.VALVE_STATE_OPEN)
-				// dev.ReportInterval(msg.SensorId, 300)
-			case ener314.SetTemperature:
-				log.Printf("%06x Set temperature: %.2f°C\n", msg.SensorId, t.Value)
-			case ener314.Voltage:
-				log.Printf("%06x Voltage: %.2fV\n", msg.SensorId, t.Value)
-			case ener314.Diagnostics:
-				log.Printf("%06x Diagnostic report: %s\n", msg.SensorId, t)
-			default:
-				log.Printf("%06x Unknown: %#v\n", msg.SensorId, t)
+	for event := range events.C {
+		switch e := event.(type) {
+		case ener314.MessageEvent:
+			logMessage(e.Message)
+		case ener314.JoinRequest:
+			if !e.Accepted {
+				log.Printf("%06x Join ignored, run with -pair to accept\n", e.SensorId)
 			}
+		case ener314.SensorEvent:
+			if e.Changed {
+				log.Printf("%06x Sensor active: %t\n", e.SensorId, e.Active)
+			}
+		case ener314.CommandResult:
+			if e.Err != nil {
+				log.Printf("%06x Command %s failed: %s\n", e.Command.SensorId, e.Command.Record, e.Err)
+			}
+		}
+	}
+}
+
+func logMessage(msg *ener314.Message) {
+	for _, record := range msg.Records {
+		switch t := record.(type) {
+		case ener314.Join:
+			log.Printf("%06x Join\n", msg.SensorId)
+		case ener314.Temperature:
+			log.Printf("%06x Temperature: %.2f°C\n", msg.SensorId, t.Value)
+			// queued commands are sent after the eTRV's next report:
+			// dev.Queue(msg.SensorId, ener314.Temperature{Value: 10})
+			// dev.Queue(msg.SensorId, ener314.Voltage{})
+			// dev.Queue(msg.SensorId, ener314.Diagnostics{})
+			// dev.Queue(msg.SensorId, ener314.SetValveState{State: ener314.VALVE_STATE_OPEN})
+			// dev.Queue(msg.SensorId, ener314.ReportInterval{Value: 300})
+		case ener314.SetTemperature:
+			log.Printf("%06x Set temperature: %.2f°C\n", msg.SensorId, t.Value)
+		case ener314.Voltage:
+			log.Printf("%06x Voltage: %.2fV\n", msg.SensorId, t.Value)
+		case ener314.Diagnostics:
+			log.Printf("%06x Diagnostic report: %s\n", msg.SensorId, t)
+		case ener314.RealPower:
+			log.Printf("%06x Real power: %.0fW\n", msg.SensorId, t.Value)
+		case ener314.ReactivePower:
+			log.Printf("%06x Reactive power: %.0fVAR\n", msg.SensorId, t.Value)
+		case ener314.Current:
+			log.Printf("%06x Current: %.2fA\n", msg.SensorId, t.Value)
+		case ener314.SwitchState:
+			log.Printf("%06x Switch on: %t\n", msg.SensorId, t.On)
+		case ener314.MotionDetector, ener314.DoorSensor:
+			// reported as a SensorEvent
+		case ener314.Frequency:
+			log.Printf("%06x Frequency: %.2fHz\n", msg.SensorId, t.Value)
+		default:
+			log.Printf("%06x Unknown: %#v\n", msg.SensorId, t)
 		}
 	}
 }
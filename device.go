@@ -1,19 +1,121 @@
 package ener314
 
-import "fmt"
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
 
+var (
+	ErrTimeout    = errors.New("Timeout waiting for confirmation")
+	ErrNotStarted = errors.New("Device not started")
+	ErrNoMonitor  = errors.New("Radio cannot measure RSSI or temperature")
+)
+
+// SendError is a failure to transmit a message to a sensor.
+type SendError struct {
+	SensorId uint32
+	Err      error
+}
+
+func (e *SendError) Error() string {
+	return fmt.Sprintf("Sending to %06x: %s", e.SensorId, e.Err)
+}
+
+func (e *SendError) Unwrap() error {
+	return e.Err
+}
+
+// DefaultDuplicateWindow covers the repeats a device sends of each message.
+const DefaultDuplicateWindow = 5 * time.Second
+
+// Device is a radio and the sensors it communicates with. Once configured,
+// its methods may be called from any number of goroutines.
 type Device struct {
-	hrf *HRF
+	DecodeOptions
+
+	// Radio sends and receives frames. If nil, Start opens the ENER314-RT.
+	Radio Radio
+	// Logger receives the Device's log output, DefaultLogger if nil.
+	Logger Logger
+	// TracePackets logs each packet sent and received in hex, unencrypted,
+	// at LOG_TRACE.
+	TracePackets bool
+
+	// DuplicateWindow is how long a frame from a sensor with the same PIP is
+	// suppressed as a duplicate. Zero disables duplicate suppression.
+	DuplicateWindow time.Duration
+	// DetectReplays flags and logs messages reusing a PIP seen from the
+	// sensor outside the duplicate window. It needs a DuplicateWindow to
+	// tell repeats from replays, so has no effect when that is zero.
+	DetectReplays bool
+	// PipSource generates the PIP of transmitted messages, DefaultPipSource
+	// if nil.
+	PipSource PipSource
+	// OnSensorEvent, if set, is called with each report from a motion or
+	// open/close sensor.
+	OnSensorEvent func(SensorEvent)
+	// CommandExpiry is how long queued commands wait for their sensor to
+	// report, see Queue. Zero keeps commands until sent.
+	CommandExpiry time.Duration
+	// CommandAttempts is how many times a queued command expecting a
+	// confirming report is sent before failing with ErrNotConfirmed. Zero
+	// means DefaultCommandAttempts.
+	CommandAttempts int
+	// OnCommandResult, if set, is called with the result of each queued
+	// command.
+	OnCommandResult func(CommandResult)
+	// OnJoinRequest, if set, is called with each join request, see
+	// OpenPairing and ApproveJoin.
+	OnJoinRequest func(JoinRequest)
+	// RegistryFile, if set, is where the registry of known sensors is
+	// loaded from by Start and saved to.
+	RegistryFile string
+
+	radioMu sync.Mutex // serialises use of the radio
+	hrf     *HRF
+	inbound []frame // received whilst switching to transmit
+
+	mu         sync.Mutex // guards the following
+	duplicates duplicateFilter
+	handles    map[handleKey]handle
+	// encryption ids identified by probing
+	encryptionIds map[productKey]byte
+	stats         deviceStats
+
+	queue   commandQueue
+	pairing pairing
+	sensors sensorRegistry
+	events  eventHub
 }
 
+// NewDevice returns a Device with the default settings. The zero Device is
+// also usable, without duplicate suppression or command expiry.
 func NewDevice() *Device {
-	return &Device{}
+	return &Device{
+		DuplicateWindow: DefaultDuplicateWindow,
+		CommandExpiry:   DefaultCommandExpiry,
+		CommandAttempts: DefaultCommandAttempts,
+	}
 }
 
+// Start loads the registry and, unless a Radio is set, resets and
+// configures the ENER314-RT.
 func (d *Device) Start() error {
-	var err error
+	err := d.LoadRegistry()
+	if err != nil {
+		return err
+	}
+	if d.Radio != nil {
+		return nil
+	}
+
+	d.radioMu.Lock()
+	defer d.radioMu.Unlock()
 
-	logs(LOG_INFO, "Resetting...")
+	d.log(LOG_INFO, "Resetting...")
 	d.hrf, err = NewHRF()
 	if err != nil {
 		return err
@@ -29,98 +131,268 @@ func (d *Device) Start() error {
 		return fmt.Errorf("Unexpected version: %d", version)
 	}
 
-	logs(LOG_INFO, "Configuring FSK")
+	d.log(LOG_INFO, "Configuring FSK")
 	err = d.hrf.ConfigFSK()
 	if err != nil {
 		return err
 	}
 
-	logs(LOG_INFO, "Wait for ready...")
+	d.log(LOG_INFO, "Wait for ready...")
 	d.hrf.WaitFor(ADDR_IRQFLAGS1, MASK_MODEREADY, true)
 
-	logs(LOG_INFO, "Clearing FIFO...")
+	d.log(LOG_INFO, "Clearing FIFO...")
 	d.hrf.ClearFifo()
+	d.Radio = d.hrf
+	d.count(func(s *deviceStats) { s.radioResets += 1 })
+	d.publish(ResetEvent{time.Now()})
 	return nil
 }
 
+// Receive returns the next message received, or nil if none is waiting or
+// the next frame is not a new message: a duplicate, or undecodable.
 func (d *Device) Receive() *Message {
-	msg := d.hrf.ReceiveFSKMessage()
-	if msg == nil {
-		return nil
+	msg, _ := d.receive()
+	return msg
+}
+
+// receive handles the next frame received, returning its message and
+// whether a frame was waiting.
+func (d *Device) receive() (*Message, bool) {
+	f, ok := d.nextFrame()
+	if !ok {
+		return nil, false
 	}
-	if msg.ManuId != energenieManuId {
-		logf(LOG_WARN, "Warning: ignored message from manufacturer %d", msg.ManuId)
-		return nil
+	return d.handleFrame(f), true
+}
+
+// handleFrame decodes a frame, returning its message unless undecodable or a
+// duplicate.
+func (d *Device) handleFrame(f frame) *Message {
+	msg, plain, err := decodeFrame(f.data, d.encryptionId, d.DecodeOptions)
+	d.countReceived(msg, f.rssi, err, time.Now())
+	if d.TracePackets {
+		d.log(LOG_TRACE, "Packet", "dir", "rx", "packet", hex.EncodeToString(plain), "rssi", f.rssi)
 	}
-	if msg.ProdId != eTRVProdId {
-		logf(LOG_WARN, "Warning: ignored message from product %d", msg.ProdId)
-		return nil
+	if err != nil {
+		d.publish(DecodeErrorEvent{f.data, msg, err, time.Now()})
+		if msg == nil {
+			d.log(LOG_ERROR, "Decode failed", "dir", "rx", "error", err)
+			return nil
+		}
+		d.log(LOG_WARN, "Partially decoded", "dir", "rx", "sensor", sensorField(msg.SensorId), "error", err)
+	}
+	d.identifiedEncryption(msg)
+	if _, ok := msg.Product(); !ok {
+		d.log(LOG_INFO, "Message from unknown product", "dir", "rx", "sensor", sensorField(msg.SensorId), "manu_id", msg.ManuId, "prod_id", msg.ProdId)
+	}
+	if d.DuplicateWindow > 0 {
+		d.mu.Lock()
+		duplicate, replay := d.duplicates.check(msg, d.DuplicateWindow, time.Now())
+		d.mu.Unlock()
+		if duplicate {
+			d.log(LOG_TRACE, "Duplicate message", "dir", "rx", "sensor", sensorField(msg.SensorId), "pip", msg.Pip)
+			return nil
+		}
+		if replay && d.DetectReplays {
+			d.log(LOG_WARN, "Replayed pip", "dir", "rx", "sensor", sensorField(msg.SensorId), "pip", msg.Pip)
+			msg.Replay = true
+		}
+	}
+	if msg.ManuId == energenieManuId {
+		switch msg.ProdId {
+		case motionProdId:
+			d.MotionSensor(msg.SensorId)
+		case openProdId:
+			d.OpenSensor(msg.SensorId)
+		case energyProdId:
+			d.EnergyMonitor(msg.SensorId)
+		}
 	}
+	d.mu.Lock()
+	h, ok := d.handles[handleKey{msg.ProdId, msg.SensorId}]
+	d.mu.Unlock()
+	if ok && msg.ManuId == energenieManuId {
+		h.update(msg, time.Now())
+	}
+	d.sensors.seen(msg, f.rssi, time.Now())
+	if d.RegistryFile != "" && d.sensors.saveDue(registrySaveInterval, time.Now()) {
+		d.saveRegistryLogged()
+	}
+	if !msg.Replay {
+		if isJoin(msg) {
+			d.handleJoin(msg)
+		}
+		d.sendQueued(msg)
+	}
+	d.publish(MessageEvent{msg, time.Now()})
 	return msg
 }
 
-func (d *Device) GetRSSI() float32 {
-	return d.hrf.GetRSSI()
+// addHandle registers the handle for a sensor. The lock must be held.
+func (d *Device) addHandle(key handleKey, h handle) {
+	if d.handles == nil {
+		d.handles = map[handleKey]handle{}
+	}
+	d.handles[key] = h
 }
 
-func (d *Device) GetTemperature() int {
-	return d.hrf.GetTemperature()
+func isJoin(msg *Message) bool {
+	for _, record := range msg.Records {
+		if _, ok := record.(Join); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Duplicates returns the number of duplicate messages suppressed.
+func (d *Device) Duplicates() uint64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.duplicates.duplicates
+}
+
+// radioMonitor returns the radio, if it is a RadioMonitor. radioMu must be
+// held.
+func (d *Device) radioMonitor() (RadioMonitor, error) {
+	if d.Radio == nil {
+		return nil, ErrNotStarted
+	}
+	m, ok := d.Radio.(RadioMonitor)
+	if !ok {
+		return nil, ErrNoMonitor
+	}
+	return m, nil
+}
+
+// GetRSSI measures the RSSI of the radio, returning ErrNoMonitor if it is
+// not a RadioMonitor.
+func (d *Device) GetRSSI() (float32, error) {
+	d.radioMu.Lock()
+	defer d.radioMu.Unlock()
+	m, err := d.radioMonitor()
+	if err != nil {
+		return 0, err
+	}
+	return m.GetRSSI(), nil
 }
 
-func (d *Device) Respond(sensorId uint32, record Record) {
+// GetTemperature measures the temperature of the radio, returning
+// ErrNoMonitor if it is not a RadioMonitor.
+func (d *Device) GetTemperature() (int, error) {
+	d.radioMu.Lock()
+	defer d.radioMu.Unlock()
+	m, err := d.radioMonitor()
+	if err != nil {
+		return 0, err
+	}
+	return m.GetTemperature(), nil
+}
+
+// Send transmits a message, see MessageBuilder.
+func (d *Device) Send(message *Message) error {
+	if message.EncryptionId == 0 {
+		m := *message
+		m.EncryptionId = d.encryptionId(message.ManuId, message.ProdId)
+		message = &m
+	}
+	data, plain := encodeFrame(message, d.PipSource)
+	if d.TracePackets {
+		d.log(LOG_TRACE, "Packet", "dir", "tx", "packet", hex.EncodeToString(plain))
+	}
+	err := d.sendFrame(data)
+	d.count(func(s *deviceStats) {
+		switch err {
+		case nil:
+			s.framesSent += 1
+		case ErrNotStarted:
+		default:
+			s.sendErrors += 1
+		}
+	})
+	if err != nil {
+		return &SendError{message.SensorId, err}
+	}
+	d.log(LOG_TRACE, "Sent", "dir", "tx", "sensor", sensorField(message.SensorId), "message", message)
+	return nil
+}
+
+func (d *Device) send(prodId byte, sensorId uint32, record Record) error {
 	message := &Message{
 		ManuId:   energenieManuId,
-		ProdId:   eTRVProdId,
+		ProdId:   prodId,
 		SensorId: sensorId,
 		Records:  []Record{record},
 	}
-	err := d.hrf.SendFSKMessage(message)
-	if err != nil {
-		logs(LOG_ERROR, "Error sending", err)
-	}
+	return d.Send(message)
 }
 
-func (d *Device) Identify(sensorId uint32) {
-	d.Respond(sensorId, Identify{})
+// Respond sends a record to an eTRV.
+func (d *Device) Respond(sensorId uint32, record Record) error {
+	return d.logError(sensorId, d.send(eTRVProdId, sensorId, record))
 }
 
-func (d *Device) Join(sensorId uint32) {
-	d.Respond(sensorId, JoinReport{})
+// The following eTRV commands are shorthand for Device.Respond, checking
+// values against the catalogue. Unlike those of Device.ETRV, they keep no
+// state. Errors are returned, and logged at LOG_ERROR.
+
+func (d *Device) Identify(sensorId uint32) error {
+	return d.Respond(sensorId, Identify{})
 }
 
-func (d *Device) Voltage(sensorId uint32) {
-	d.Respond(sensorId, Voltage{})
+func (d *Device) Join(sensorId uint32) error {
+	return d.Respond(sensorId, JoinReport{})
 }
 
-func (d *Device) ExerciseValve(sensorId uint32) {
-	d.Respond(sensorId, ExerciseValve{})
+func (d *Device) Voltage(sensorId uint32) error {
+	return d.Respond(sensorId, Voltage{})
 }
 
-func (d *Device) Diagnostics(sensorId uint32) {
-	d.Respond(sensorId, Diagnostics{})
+func (d *Device) ExerciseValve(sensorId uint32) error {
+	return d.Respond(sensorId, ExerciseValve{})
 }
 
-func (d *Device) TargetTemperature(sensorId uint32, temp float64) {
-	if temp < 0 || temp > 30 {
-		logf(LOG_WARN, "Temperature out of range: 0 < %.2f < 30, refusing", temp)
-		return
-	}
-	d.Respond(sensorId, Temperature{temp})
+func (d *Device) Diagnostics(sensorId uint32) error {
+	return d.Respond(sensorId, Diagnostics{})
 }
 
-func (d *Device) ReportInterval(sensorId uint32, interval uint16) {
-	if interval < 1 || interval > 3600 {
-		logf(LOG_WARN, "Interval out of range: 1 < %d < 3600, refusing", interval)
-		return
+// TargetTemperature sets the target temperature, returning a *RangeError
+// outside 0-30°C.
+func (d *Device) TargetTemperature(sensorId uint32, temp float64) error {
+	return d.respondChecked(sensorId, OT_TEMP_SET, temp, Temperature{temp})
+}
+
+// ReportInterval sets the interval between reports, returning a *RangeError
+// outside 1-3600 seconds.
+func (d *Device) ReportInterval(sensorId uint32, interval uint16) error {
+	if err := checkRange(eTRVProdId, OT_SET_REPORTING_INTERVAL, float64(interval)); err != nil {
+		return d.logError(sensorId, err)
 	}
-	logf(LOG_INFO, "Setting report interval for device %06x to %ds", sensorId, interval)
-	d.Respond(sensorId, ReportInterval{interval})
+	d.log(LOG_INFO, "Setting report interval", "sensor", sensorField(sensorId), "interval", interval)
+	return d.Respond(sensorId, ReportInterval{interval})
+}
+
+func (d *Device) SetValveState(sensorId uint32, valveState ValveState) error {
+	return d.respondChecked(sensorId, OT_SET_VALVE_STATE, float64(valveState), SetValveState{valveState})
+}
+
+func (d *Device) SetPowerMode(sensorId uint32, mode PowerMode) error {
+	return d.respondChecked(sensorId, OT_SET_LOW_POWER_MODE, float64(mode), SetPowerMode{mode})
 }
 
-func (d *Device) SetValveState(sensorId uint32, valveState ValveState) {
-	d.Respond(sensorId, SetValveState{valveState})
+// respondChecked sends a command to an eTRV if its value is in range.
+func (d *Device) respondChecked(sensorId uint32, paramId byte, value float64, record Record) error {
+	if err := checkRange(eTRVProdId, paramId, value); err != nil {
+		return d.logError(sensorId, err)
+	}
+	return d.Respond(sensorId, record)
 }
 
-func (d *Device) SetPowerMode(sensorId uint32, mode PowerMode) {
-	d.Respond(sensorId, SetPowerMode{mode})
+// logError logs a failed eTRV command sent directly, as distinct from the
+// queued commands reported by commandResults.
+func (d *Device) logError(sensorId uint32, err error) error {
+	if err != nil {
+		d.log(LOG_ERROR, "Sending command failed", "dir", "tx", "sensor", sensorField(sensorId), "error", err)
+	}
+	return err
 }
@@ -0,0 +1,52 @@
+package ener314
+
+import "time"
+
+const pipHistory = 16 // PIPs remembered per sensor for replay detection
+
+type sensorKey struct {
+	manuId, prodId byte
+	sensorId       uint32
+}
+
+type pipSeen struct {
+	pip uint16
+	at  time.Time
+}
+
+// duplicateFilter recognises repeated transmissions of the same frame by the
+// sensor and PIP.
+type duplicateFilter struct {
+	sensors    map[sensorKey][]pipSeen
+	duplicates uint64
+	replays    uint64
+}
+
+// check returns whether msg repeats a frame seen within window, or reuses a
+// PIP seen from the sensor longer ago than that (a replay).
+func (f *duplicateFilter) check(msg *Message, window time.Duration, now time.Time) (duplicate, replay bool) {
+	key := sensorKey{msg.ManuId, msg.ProdId, msg.SensorId}
+	seen := f.sensors[key]
+	for i, s := range seen {
+		if s.pip != msg.Pip {
+			continue
+		}
+		if now.Sub(s.at) <= window {
+			f.duplicates += 1
+			return true, false
+		}
+		f.replays += 1
+		seen = append(seen[:i], seen[i+1:]...)
+		replay = true
+		break
+	}
+	seen = append(seen, pipSeen{msg.Pip, now})
+	if len(seen) > pipHistory {
+		seen = seen[len(seen)-pipHistory:]
+	}
+	if f.sensors == nil {
+		f.sensors = map[sensorKey][]pipSeen{}
+	}
+	f.sensors[key] = seen
+	return false, replay
+}
@@ -0,0 +1,63 @@
+package ener314
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDuplicateFilter(t *testing.T) {
+	f := &duplicateFilter{}
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	window := 5 * time.Second
+	msg := &Message{ManuId: energenieManuId, ProdId: eTRVProdId, SensorId: 0x00097f, Pip: 0x1234}
+	other := &Message{ManuId: energenieManuId, ProdId: eTRVProdId, SensorId: 0x000980, Pip: 0x1234}
+
+	duplicate, replay := f.check(msg, window, now)
+	assert.False(t, duplicate)
+	assert.False(t, replay)
+
+	// repeat transmission
+	duplicate, _ = f.check(msg, window, now.Add(time.Second))
+	assert.True(t, duplicate)
+
+	// same pip, different sensor
+	duplicate, _ = f.check(other, window, now.Add(time.Second))
+	assert.False(t, duplicate)
+
+	// new pip
+	msg.Pip = 0x1235
+	duplicate, _ = f.check(msg, window, now.Add(2*time.Second))
+	assert.False(t, duplicate)
+
+	// old pip again, long after
+	msg.Pip = 0x1234
+	duplicate, replay = f.check(msg, window, now.Add(time.Minute))
+	assert.False(t, duplicate)
+	assert.True(t, replay)
+
+	assert.Equal(t, uint64(1), f.duplicates)
+	assert.Equal(t, uint64(1), f.replays)
+}
+
+func TestDuplicateFilterHistory(t *testing.T) {
+	f := &duplicateFilter{}
+	now := time.Now()
+	msg := &Message{SensorId: 0x00097f}
+	for pip := 0; pip <= pipHistory; pip += 1 {
+		msg.Pip = uint16(pip)
+		f.check(msg, time.Second, now)
+	}
+	// pip 0 has been forgotten
+	msg.Pip = 0
+	_, replay := f.check(msg, time.Second, now.Add(time.Minute))
+	assert.False(t, replay)
+}
+
+func TestDecodePip(t *testing.T) {
+	packet := []byte{0x04, 0x03, 0x04, 0x42, 0xd1, 0xf8, 0x17, 0x05, 0xd1, 0xd9, 0x0f, 0x30}
+	message, err := Decode(packet, DecodeOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, uint16(0x0442), message.Pip)
+}
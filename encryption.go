@@ -0,0 +1,158 @@
+package ener314
+
+import (
+	"sort"
+	"sync"
+)
+
+// DefaultEncryptionId is used for products without a registered encryption id.
+const DefaultEncryptionId = 0xf2
+
+type productKey struct {
+	manuId, prodId byte
+}
+
+var (
+	encryptionLock sync.RWMutex
+	encryptionIds  = map[productKey]byte{} // populated from the product catalogue
+)
+
+// SetEncryptionId sets the encryption id used for a manufacturer/product.
+func SetEncryptionId(manuId, prodId, encryptionId byte) {
+	encryptionLock.Lock()
+	defer encryptionLock.Unlock()
+	encryptionIds[productKey{manuId, prodId}] = encryptionId
+}
+
+// EncryptionId returns the encryption id used for a manufacturer/product.
+func EncryptionId(manuId, prodId byte) byte {
+	encryptionLock.RLock()
+	defer encryptionLock.RUnlock()
+	if id, ok := encryptionIds[productKey{manuId, prodId}]; ok {
+		return id
+	}
+	return DefaultEncryptionId
+}
+
+// knownEncryptionIds returns each distinct encryption id in the table.
+func knownEncryptionIds() []byte {
+	encryptionLock.RLock()
+	defer encryptionLock.RUnlock()
+	seen := map[byte]bool{DefaultEncryptionId: true}
+	ids := []byte{DefaultEncryptionId}
+	for _, id := range encryptionIds {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// probeCandidates are the encryption ids to probe: those of catalogued
+// products, then the given candidates or else every id.
+func probeCandidates(candidates []byte) []byte {
+	ids := knownEncryptionIds()
+	if candidates == nil {
+		for id := 0; id <= 0xff; id += 1 {
+			ids = append(ids, byte(id))
+		}
+	} else {
+		ids = append(ids, candidates...)
+	}
+	seen := map[byte]bool{}
+	ret := ids[:0]
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			ret = append(ret, id)
+		}
+	}
+	return ret
+}
+
+// DecodeOptions control how received packets are decoded.
+type DecodeOptions struct {
+	// ProbeEncryption tries other encryption ids on packets that fail their
+	// CRC, to identify the encryption id of unknown products.
+	ProbeEncryption bool
+	// EncryptionCandidates are the encryption ids probed after those of
+	// catalogued products. If nil, every encryption id is tried.
+	EncryptionCandidates []byte
+	// Lenient returns the records decoded before a malformed record, along
+	// with the *DecodeError.
+	Lenient bool
+}
+
+// Decode decrypts and decodes a received packet. If the CRC fails and
+// ProbeEncryption is set, the candidate encryption ids are tried in turn and
+// the first with which the whole packet decodes is returned in the message's
+// EncryptionId. It is not remembered: a Device remembers the encryption ids
+// it identifies, or use SetEncryptionId.
+func Decode(packet []byte, opts DecodeOptions) (*Message, error) {
+	message, _, err := decodeFrame(packet, EncryptionId, opts)
+	return message, err
+}
+
+// decodeFrame is Decode with the encryption ids from lookup, also returning
+// the decrypted packet.
+func decodeFrame(packet []byte, lookup func(manuId, prodId byte) byte, opts DecodeOptions) (*Message, []byte, error) {
+	data := append([]byte(nil), packet...)
+	encryptionId := byte(DefaultEncryptionId)
+	if len(data) >= 2 {
+		encryptionId = lookup(data[0], data[1])
+	}
+	cryptPacketId(data, encryptionId)
+	message, err := decodePacketLenient(data, opts.Lenient)
+	if err == ErrCRCFail && opts.ProbeEncryption {
+		for _, id := range probeCandidates(opts.EncryptionCandidates) {
+			if id == encryptionId {
+				continue
+			}
+			copy(data, packet)
+			cryptPacketId(data, id)
+			// a 16 bit CRC matches by chance too often to rely on alone
+			if m, e := decodePacket(data); e == nil {
+				message, err, encryptionId = m, e, id
+				break
+			}
+		}
+		if err != nil {
+			copy(data, packet)
+			cryptPacketId(data, encryptionId)
+		}
+	}
+	if message != nil {
+		message.EncryptionId = encryptionId
+	}
+	return message, data, err
+}
+
+// encryptionId is the encryption id the Device uses for a
+// manufacturer/product: one it has identified by probing, or else the
+// registered one.
+func (d *Device) encryptionId(manuId, prodId byte) byte {
+	d.mu.Lock()
+	id, ok := d.encryptionIds[productKey{manuId, prodId}]
+	d.mu.Unlock()
+	if ok {
+		return id
+	}
+	return EncryptionId(manuId, prodId)
+}
+
+// identifiedEncryption remembers the encryption id a message was decoded
+// with, if found by probing.
+func (d *Device) identifiedEncryption(msg *Message) {
+	if msg.EncryptionId == d.encryptionId(msg.ManuId, msg.ProdId) {
+		return
+	}
+	d.log(LOG_INFO, "Identified encryption id", "sensor", sensorField(msg.SensorId), "encryption_id", msg.EncryptionId, "manu_id", msg.ManuId, "prod_id", msg.ProdId)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.encryptionIds == nil {
+		d.encryptionIds = map[productKey]byte{}
+	}
+	d.encryptionIds[productKey{msg.ManuId, msg.ProdId}] = msg.EncryptionId
+}
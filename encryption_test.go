@@ -0,0 +1,91 @@
+package ener314
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncryptionId(t *testing.T) {
+	assert.Equal(t, byte(0xf2), EncryptionId(energenieManuId, eTRVProdId))
+	assert.Equal(t, byte(DefaultEncryptionId), EncryptionId(0x7d, 0x01))
+
+	SetEncryptionId(0x7d, 0x02, 0x33)
+	t.Cleanup(func() { unsetEncryptionId(0x7d, 0x02) })
+	assert.Equal(t, byte(0x33), EncryptionId(0x7d, 0x02))
+	assert.Contains(t, knownEncryptionIds(), byte(0x33))
+}
+
+// unsetEncryptionId restores the default encryption id of a product.
+func unsetEncryptionId(manuId, prodId byte) {
+	encryptionLock.Lock()
+	defer encryptionLock.Unlock()
+	delete(encryptionIds, productKey{manuId, prodId})
+}
+
+// encryptedJoin is a join from an unknown product encrypted with 0x55.
+func encryptedJoin(pip uint16) []byte {
+	message := &Message{ManuId: 0x7e, ProdId: 0x01, SensorId: 0x123456, Records: []Record{Join{}}}
+	data := encodeMessage(message)
+	encryptData(data, 0x55, pip)
+	return data
+}
+
+func TestDecodeProbe(t *testing.T) {
+	data := encryptedJoin(0x1234)
+
+	_, err := Decode(data, DecodeOptions{})
+	assert.Equal(t, ErrCRCFail, err)
+
+	decoded, err := Decode(data, DecodeOptions{ProbeEncryption: true})
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(0x123456), decoded.SensorId)
+	assert.Equal(t, byte(0x55), decoded.EncryptionId)
+
+	// not remembered
+	assert.Equal(t, byte(DefaultEncryptionId), EncryptionId(0x7e, 0x01))
+	_, err = Decode(data, DecodeOptions{})
+	assert.Equal(t, ErrCRCFail, err)
+
+	_, err = Decode(data, DecodeOptions{ProbeEncryption: true, EncryptionCandidates: []byte{0x33}})
+	assert.Equal(t, ErrCRCFail, err)
+}
+
+func TestDeviceProbe(t *testing.T) {
+	radio := &fakeRadio{}
+	logger := &recordingLogger{}
+	dev := NewDevice()
+	dev.Radio = radio
+	dev.Logger = logger
+	dev.ProbeEncryption = true
+	assert.NoError(t, dev.Start())
+
+	radio.inject(encryptedJoin(1))
+	msg := dev.Receive()
+	if assert.NotNil(t, msg) {
+		assert.Equal(t, byte(0x55), msg.EncryptionId)
+	}
+	assert.Len(t, logger.find("Identified encryption id"), 1)
+
+	// remembered by the device, not globally
+	dev.ProbeEncryption = false
+	radio.inject(encryptedJoin(2))
+	assert.NotNil(t, dev.Receive())
+	assert.Equal(t, byte(DefaultEncryptionId), EncryptionId(0x7e, 0x01))
+
+	assert.NoError(t, dev.Send(&Message{ManuId: 0x7e, ProdId: 0x01, SensorId: 0x123456, Records: []Record{Identify{}}}))
+	sent := radio.sentFrames()
+	if assert.Len(t, sent, 1) {
+		data := append([]byte(nil), sent[0]...)
+		cryptPacketId(data, 0x55)
+		_, err := decodePacket(data)
+		assert.NoError(t, err)
+	}
+}
+
+func TestMessageEncryptionOverride(t *testing.T) {
+	message, _ := NewMessageBuilder().Sensor(0x00097f).Encryption(0x42).Build()
+	assert.Equal(t, byte(0x42), message.encryptionId())
+	message.EncryptionId = 0
+	assert.Equal(t, byte(0xf2), message.encryptionId())
+}
@@ -0,0 +1,69 @@
+package ener314
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnergyMonitorUpdate(t *testing.T) {
+	dev := NewDevice()
+	monitor := dev.EnergyMonitor(0x000abc)
+	assert.Same(t, monitor, dev.EnergyMonitor(0x000abc))
+	monitor.NominalVoltage = 250
+	monitor.MaxInterval = time.Hour
+
+	start := time.Now()
+	for i, records := range [][]Record{
+		{Current{4}},
+		{Current{4}},
+		{RealPower{2000}},
+		{Voltage{240}},
+	} {
+		monitor.update(&Message{
+			ManuId: energenieManuId, ProdId: energyProdId, SensorId: 0x000abc,
+			Records: records,
+		}, start.Add(time.Duration(i)*30*time.Minute))
+	}
+
+	// 1kW for half an hour, then rising to 2kW over half an hour
+	assert.Equal(t, EnergyReadings{
+		Current: 4,
+		Power:   2000,
+		Energy:  1.25,
+		Updated: start.Add(time.Hour),
+	}, monitor.Readings())
+
+	monitor.ResetEnergy()
+	assert.Equal(t, 0.0, monitor.Readings().Energy)
+}
+
+func TestEnergyMonitorGap(t *testing.T) {
+	dev := NewDevice()
+	monitor := dev.EnergyMonitor(0x000abc)
+
+	start := time.Now()
+	for _, d := range []time.Duration{0, 10 * time.Second, 2 * time.Hour} {
+		monitor.update(&Message{
+			ManuId: energenieManuId, ProdId: energyProdId, SensorId: 0x000abc,
+			Records: []Record{RealPower{3600}},
+		}, start.Add(d))
+	}
+
+	// only the 10 seconds between the first reports
+	assert.InDelta(t, 0.01, monitor.Readings().Energy, 1e-9)
+}
+
+func TestEnergyMonitorReceive(t *testing.T) {
+	radio := &fakeRadio{}
+	dev := NewDevice()
+	dev.Radio = radio
+	radio.inject(Encode(&Message{
+		ManuId: energenieManuId, ProdId: energyProdId, SensorId: 0x000abc,
+		Records: []Record{RealPower{1500}},
+	}, NewSequencePipSource(1)))
+
+	assert.NotNil(t, dev.Receive())
+	assert.Equal(t, 1500.0, dev.EnergyMonitor(0x000abc).Readings().Power)
+}
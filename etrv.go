@@ -0,0 +1,175 @@
+package ener314
+
+import (
+	"sync"
+	"time"
+)
+
+// ETRVState is the last known state of an eTRV. Temperature, Voltage and
+// Diagnostics are reported by the eTRV; the others are as last commanded.
+// Each has the time it was last updated, zero if never.
+type ETRVState struct {
+	Temperature           float64 // °C
+	TemperatureUpdated    time.Time
+	Target                float64 // °C
+	TargetUpdated         time.Time
+	Voltage               float64 // V
+	VoltageUpdated        time.Time
+	Diagnostics           Diagnostics
+	DiagnosticsUpdated    time.Time
+	ValveState            ValveState
+	ValveStateUpdated     time.Time
+	PowerMode             PowerMode
+	PowerModeUpdated      time.Time
+	ReportInterval        uint16 // seconds
+	ReportIntervalUpdated time.Time
+}
+
+// ETRV is a MIHO013 eTRV radiator valve.
+//
+// The eTRV only listens briefly after each report, so commands are best sent
+// on receipt of a report.
+type ETRV struct {
+	SensorId uint32
+
+	dev   *Device
+	mu    sync.Mutex
+	state ETRVState
+}
+
+// ETRV returns the handle for the MIHO013 eTRV with the given id.
+func (d *Device) ETRV(sensorId uint32) *ETRV {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if e, ok := d.handles[handleKey{eTRVProdId, sensorId}].(*ETRV); ok {
+		return e
+	}
+	e := &ETRV{SensorId: sensorId, dev: d}
+	d.addHandle(handleKey{eTRVProdId, sensorId}, e)
+	return e
+}
+
+// State returns the last known state.
+func (e *ETRV) State() ETRVState {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.state
+}
+
+func (e *ETRV) send(record Record) error {
+	return e.dev.send(eTRVProdId, e.SensorId, record)
+}
+
+// checkRange checks a command value against the range in the catalogue.
+func checkRange(prodId, paramId byte, value float64) error {
+	p, ok := LookupProduct(energenieManuId, prodId)
+	if !ok {
+		return nil
+	}
+	return p.checkCommand(paramId, value)
+}
+
+// Identify asks the eTRV to identify itself.
+func (e *ETRV) Identify() error {
+	return e.send(Identify{})
+}
+
+// Join acknowledges a join request from the eTRV.
+func (e *ETRV) Join() error {
+	return e.send(JoinReport{})
+}
+
+// RequestVoltage asks the eTRV to report its battery voltage.
+func (e *ETRV) RequestVoltage() error {
+	return e.send(Voltage{})
+}
+
+// RequestDiagnostics asks the eTRV to report its diagnostic flags.
+func (e *ETRV) RequestDiagnostics() error {
+	return e.send(Diagnostics{})
+}
+
+// ExerciseValve asks the eTRV to exercise its valve. The result is reported
+// in the diagnostic flags.
+func (e *ETRV) ExerciseValve() error {
+	return e.send(ExerciseValve{})
+}
+
+// SetTargetTemperature sets the target temperature, 0-30°C.
+func (e *ETRV) SetTargetTemperature(temp float64) error {
+	if err := checkRange(eTRVProdId, OT_TEMP_SET, temp); err != nil {
+		return err
+	}
+	if err := e.send(Temperature{temp}); err != nil {
+		return err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.state.Target, e.state.TargetUpdated = temp, time.Now()
+	return nil
+}
+
+// SetReportInterval sets the interval between reports, 1-3600 seconds.
+func (e *ETRV) SetReportInterval(interval uint16) error {
+	if err := checkRange(eTRVProdId, OT_SET_REPORTING_INTERVAL, float64(interval)); err != nil {
+		return err
+	}
+	if err := e.send(ReportInterval{interval}); err != nil {
+		return err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.state.ReportInterval, e.state.ReportIntervalUpdated = interval, time.Now()
+	return nil
+}
+
+// SetValveState sets the valve open, closed or under temperature control.
+func (e *ETRV) SetValveState(valveState ValveState) error {
+	if err := checkRange(eTRVProdId, OT_SET_VALVE_STATE, float64(valveState)); err != nil {
+		return err
+	}
+	if err := e.send(SetValveState{valveState}); err != nil {
+		return err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.state.ValveState, e.state.ValveStateUpdated = valveState, time.Now()
+	return nil
+}
+
+// SetPowerMode sets normal or low power mode.
+func (e *ETRV) SetPowerMode(mode PowerMode) error {
+	if err := checkRange(eTRVProdId, OT_SET_LOW_POWER_MODE, float64(mode)); err != nil {
+		return err
+	}
+	if err := e.send(SetPowerMode{mode}); err != nil {
+		return err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.state.PowerMode, e.state.PowerModeUpdated = mode, time.Now()
+	return nil
+}
+
+func (e *ETRV) update(msg *Message, now time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	s := &e.state
+	for _, record := range msg.Records {
+		switch t := record.(type) {
+		case Temperature:
+			s.Temperature, s.TemperatureUpdated = t.Value, now
+		case SetTemperature:
+			s.Target, s.TargetUpdated = t.Value, now
+		case Voltage:
+			s.Voltage, s.VoltageUpdated = t.Value, now
+		case Diagnostics:
+			s.Diagnostics, s.DiagnosticsUpdated = t, now
+			// the low power mode flag reports the actual power mode
+			s.PowerMode, s.PowerModeUpdated = POWER_MODE_NORMAL, now
+			if t.Value&(1<<6) != 0 {
+				s.PowerMode = POWER_MODE_LOW
+			}
+		}
+	}
+}
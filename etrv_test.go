@@ -0,0 +1,73 @@
+package ener314
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestETRVUpdate(t *testing.T) {
+	dev := NewDevice()
+	etrv := dev.ETRV(0x00097f)
+	assert.Same(t, etrv, dev.ETRV(0x00097f))
+
+	now := time.Now()
+	etrv.update(&Message{
+		ManuId: energenieManuId, ProdId: eTRVProdId, SensorId: 0x00097f,
+		Records: []Record{Temperature{18.5}, Voltage{3.1}, Diagnostics{0x0240}},
+	}, now)
+
+	state := etrv.State()
+	assert.Equal(t, 18.5, state.Temperature)
+	assert.Equal(t, now, state.TemperatureUpdated)
+	assert.Equal(t, 3.1, state.Voltage)
+	assert.Equal(t, now, state.VoltageUpdated)
+	assert.Equal(t, []string{"Low power mode is enabled", "Valve exercise was successful"}, state.Diagnostics.Flags())
+	assert.Equal(t, POWER_MODE_LOW, state.PowerMode)
+	assert.True(t, state.TargetUpdated.IsZero())
+}
+
+func TestETRVCommandRange(t *testing.T) {
+	etrv := NewDevice().ETRV(0x00097f)
+	err := etrv.SetTargetTemperature(31)
+	assert.EqualError(t, err, "TARGET_TEMPERATURE out of range: 0 < 31 < 30")
+	var rangeErr *RangeError
+	if assert.True(t, errors.As(err, &rangeErr)) {
+		assert.Equal(t, &RangeError{OT_TEMP_SET, 31, 0, 30}, rangeErr)
+	}
+	assert.True(t, errors.As(etrv.SetReportInterval(0), &rangeErr))
+	assert.True(t, errors.As(etrv.SetValveState(ValveState(3)), &rangeErr))
+	assert.True(t, etrv.State().TargetUpdated.IsZero())
+}
+
+func TestDeviceCommandErrors(t *testing.T) {
+	dev := NewDevice()
+	var rangeErr *RangeError
+	assert.True(t, errors.As(dev.TargetTemperature(0x00097f, -1), &rangeErr))
+	assert.True(t, errors.As(dev.ReportInterval(0x00097f, 3601), &rangeErr))
+
+	err := dev.TargetTemperature(0x00097f, 21)
+	assert.EqualError(t, err, "Sending to 00097f: Device not started")
+	var sendErr *SendError
+	if assert.True(t, errors.As(err, &sendErr)) {
+		assert.Equal(t, uint32(0x00097f), sendErr.SensorId)
+	}
+	assert.True(t, errors.Is(err, ErrNotStarted))
+	assert.True(t, errors.Is(dev.Voltage(0x00097f), ErrNotStarted))
+	assert.True(t, errors.Is(dev.AdapterPlus(0x000456).SwitchConfirmed(true, time.Second), ErrNotStarted))
+}
+
+func TestDeviceCommandsKeepHandles(t *testing.T) {
+	dev := NewDevice()
+	dev.Radio = &fakeRadio{}
+	monitor := dev.Monitor(0x00097f)
+	assert.NoError(t, dev.Identify(0x00097f))
+	assert.NoError(t, dev.TargetTemperature(0x00097f, 21))
+	assert.Len(t, dev.handles, 1)
+
+	etrv := dev.ETRV(0x00097f)
+	assert.Same(t, monitor, dev.Monitor(0x00097f))
+	assert.Same(t, etrv, dev.ETRV(0x00097f))
+}
@@ -0,0 +1,190 @@
+package ener314
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// Event is delivered to subscribers, see Device.Subscribe. Events are
+// MessageEvent, DecodeErrorEvent, JoinRequest, SensorEvent, CommandResult
+// and ResetEvent.
+type Event interface {
+	event()
+}
+
+// MessageEvent is a message received, after duplicate suppression.
+type MessageEvent struct {
+	Message *Message
+	Time    time.Time
+}
+
+// DecodeErrorEvent is a frame received that could not be decoded, or only
+// partially decoded when DecodeOptions.Lenient is set.
+type DecodeErrorEvent struct {
+	Frame   []byte   // as received, encrypted
+	Message *Message // the records decoded before the error, if lenient
+	Err     error
+	Time    time.Time
+}
+
+// ResetEvent is the radio being reset, by Device.Start.
+type ResetEvent struct {
+	Time time.Time
+}
+
+func (MessageEvent) event()     {}
+func (DecodeErrorEvent) event() {}
+func (ResetEvent) event()       {}
+func (JoinRequest) event()      {}
+func (SensorEvent) event()      {}
+func (CommandResult) event()    {}
+
+// EventFilter selects the events a subscriber receives.
+type EventFilter func(Event) bool
+
+// ForSensor selects the events relating to a sensor.
+func ForSensor(sensorId uint32) EventFilter {
+	return func(e Event) bool {
+		switch t := e.(type) {
+		case MessageEvent:
+			return t.Message.SensorId == sensorId
+		case DecodeErrorEvent:
+			return t.Message != nil && t.Message.SensorId == sensorId
+		case JoinRequest:
+			return t.SensorId == sensorId
+		case SensorEvent:
+			return t.SensorId == sensorId
+		case CommandResult:
+			return t.Command.SensorId == sensorId
+		}
+		return false
+	}
+}
+
+// OfType selects events of the same types as the examples given, for
+// example OfType(JoinRequest{}, SensorEvent{}).
+func OfType(examples ...Event) EventFilter {
+	types := map[reflect.Type]bool{}
+	for _, e := range examples {
+		types[reflect.TypeOf(e)] = true
+	}
+	return func(e Event) bool {
+		return types[reflect.TypeOf(e)]
+	}
+}
+
+// Subscription delivers events on C until closed, or until Device.Run
+// returns.
+type Subscription struct {
+	C <-chan Event
+
+	c       chan Event
+	filters []EventFilter
+	events  *eventHub
+}
+
+// Close stops delivery of events and closes C.
+func (s *Subscription) Close() {
+	s.events.unsubscribe(s)
+}
+
+// eventHub fans events out to subscribers.
+type eventHub struct {
+	mu          sync.Mutex
+	subscribers []*Subscription
+	dropped     uint64
+}
+
+func (h *eventHub) subscribe(buffer int, filters []EventFilter) *Subscription {
+	c := make(chan Event, buffer)
+	s := &Subscription{C: c, c: c, filters: filters, events: h}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.subscribers = append(h.subscribers, s)
+	return s
+}
+
+func (h *eventHub) unsubscribe(s *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i, sub := range h.subscribers {
+		if sub == s {
+			h.subscribers = append(h.subscribers[:i], h.subscribers[i+1:]...)
+			close(s.c)
+			return
+		}
+	}
+}
+
+func (h *eventHub) closeAll() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, s := range h.subscribers {
+		close(s.c)
+	}
+	h.subscribers = nil
+}
+
+// publish delivers an event to each subscriber whose filters all select it,
+// returning the number of subscribers not keeping up, for whom the event is
+// dropped.
+func (h *eventHub) publish(e Event) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	dropped := 0
+subscribers:
+	for _, s := range h.subscribers {
+		for _, filter := range s.filters {
+			if !filter(e) {
+				continue subscribers
+			}
+		}
+		select {
+		case s.c <- e:
+		default:
+			h.dropped += 1
+			dropped += 1
+		}
+	}
+	return dropped
+}
+
+// publish publishes an event to the Device's subscribers.
+func (d *Device) publish(e Event) {
+	if d.events.publish(e) > 0 {
+		d.log(LOG_WARN, "Subscriber not keeping up, dropped event", "event", fmt.Sprintf("%T", e))
+	}
+}
+
+// Subscribe delivers the events selected by all filters on a channel with
+// the given buffer size. Events are dropped if the buffer is full.
+func (d *Device) Subscribe(buffer int, filters ...EventFilter) *Subscription {
+	return d.events.subscribe(buffer, filters)
+}
+
+// receivePollInterval is how long Run waits when no frame is waiting.
+const receivePollInterval = 100 * time.Millisecond
+
+// Run receives messages, publishing events to subscribers, until ctx is
+// cancelled. Subscriptions are then closed, and ctx.Err() returned.
+func (d *Device) Run(ctx context.Context) error {
+	defer d.events.closeAll()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+		if _, ok := d.receive(); ok {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(receivePollInterval):
+		}
+	}
+}
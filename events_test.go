@@ -0,0 +1,48 @@
+package ener314
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubscribe(t *testing.T) {
+	dev := NewDevice()
+	all := dev.Subscribe(10)
+	joins := dev.Subscribe(10, OfType(JoinRequest{}), ForSensor(0x000001))
+
+	now := time.Now()
+	message := MessageEvent{&Message{SensorId: 0x000001}, now}
+	join := JoinRequest{SensorId: 0x000001, Time: now}
+	dev.events.publish(message)
+	dev.events.publish(JoinRequest{SensorId: 0x000002, Time: now})
+	dev.events.publish(join)
+
+	assert.Equal(t, Event(message), <-all.C)
+	assert.Len(t, all.C, 2)
+	assert.Equal(t, Event(join), <-joins.C)
+	assert.Empty(t, joins.C)
+
+	joins.Close()
+	_, ok := <-joins.C
+	assert.False(t, ok)
+	joins.Close()
+
+	// full subscribers miss events rather than blocking
+	full := dev.Subscribe(0)
+	dev.events.publish(join)
+	assert.Empty(t, full.C)
+	assert.Equal(t, uint64(1), dev.events.dropped)
+}
+
+func TestRunCancelled(t *testing.T) {
+	dev := NewDevice()
+	sub := dev.Subscribe(1)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Equal(t, context.Canceled, dev.Run(ctx))
+	_, ok := <-sub.C
+	assert.False(t, ok)
+}
@@ -2,7 +2,6 @@ package ener314
 
 import (
 	"bytes"
-	"encoding/hex"
 	"time"
 
 	"github.com/barnybug/ener314/rpio"
@@ -288,36 +287,51 @@ func (self *HRF) GetTemperature() int {
 }
 
 func (self *HRF) ReceiveFSKMessage() *Message {
+	data, _ := self.ReceiveFrame()
+	if data == nil {
+		return nil
+	}
+	message, err := Decode(data, DecodeOptions{})
+	if err != nil {
+		logs(LOG_ERROR, "Error:", err)
+		return nil
+	}
+	return message
+}
+
+// ReceiveFrame returns the next received (still encrypted) packet and its
+// RSSI, or nil.
+func (self *HRF) ReceiveFrame() ([]byte, float32) {
 	if self.regR(ADDR_IRQFLAGS2)&MASK_PAYLOADRDY == MASK_PAYLOADRDY {
 		// light green whilst receiving
 		green := rpio.Pin(GreenLed)
 		green.High()
 
+		// the RSSI of the frame, still latched until the receiver restarts
+		rssi := -float32(self.regR(ADDR_RSSIVALUE)) / 2
 		length := self.regR(ADDR_FIFO)
 		data := make([]byte, length)
 		for i := 0; i < int(length); i += 1 {
 			data[i] = self.regR(ADDR_FIFO)
 		}
 		green.Low()
-
-		cryptPacket(data)
-		logs(LOG_TRACE, "<-", hex.EncodeToString(data)) // log decrypted packet
-		message, err := decodePacket(data)
-		if err != nil {
-			logs(LOG_ERROR, "Error:", err)
-			return nil
-		}
-		return message
+		return data, rssi
 	}
 
-	return nil
+	return nil, 0
 }
 
 func (self *HRF) SendFSKMessage(msg *Message) error {
-	data := encodeMessage(msg)
-	logs(LOG_TRACE, "->", hex.EncodeToString(data)) // log decrypted packet
-	encryptData(data)
+	err := self.SendFrame(Encode(msg, DefaultPipSource))
+	if err != nil {
+		return err
+	}
+	logs(LOG_TRACE, "Sent:", msg)
+	return nil
+}
 
+// SendFrame transmits an encrypted packet, returning to receive mode.
+func (self *HRF) SendFrame(data []byte) error {
 	var buf bytes.Buffer
 	buf.WriteByte(MASK_WRITE_DATA) // address
 	buf.WriteByte(byte(len(data))) // packet length
@@ -352,8 +366,6 @@ func (self *HRF) SendFSKMessage(msg *Message) error {
 	self.WaitFor(ADDR_IRQFLAGS1, MASK_MODEREADY, true)
 
 	red.Low()
-	logs(LOG_TRACE, "Sent:", msg)
-
 	return nil
 }
 
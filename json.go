@@ -0,0 +1,452 @@
+package ener314
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// jsonRecord is the JSON form of every Record: its parameter and value.
+// Records are decoded from JSON with the decoder registered for the
+// parameter, so any registered record round trips.
+type jsonRecord struct {
+	Type     string          `json:"type"`
+	Param    byte            `json:"param"`
+	Encoding *byte           `json:"encoding,omitempty"` // UnhandledRecord only
+	Value    json.RawMessage `json:"value,omitempty"`
+	Unit     string          `json:"unit,omitempty"`
+}
+
+// MarshalRecord is the JSON form of a record, for example:
+//
+//	{"type":"Temperature","param":116,"value":18.5,"unit":"°C"}
+func MarshalRecord(record Record) ([]byte, error) {
+	p := RecordParameter(record)
+	jr := jsonRecord{
+		Type:  reflect.TypeOf(record).Name(),
+		Param: p.ID,
+		Unit:  p.Unit,
+	}
+	var value interface{}
+	if t, ok := record.(UnhandledRecord); ok {
+		encoding := t.Type >> 4
+		jr.Encoding = &encoding
+		value = hex.EncodeToString(t.Value)
+	} else {
+		value = recordValue(record)
+	}
+	if value != nil {
+		b, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		jr.Value = b
+	}
+	return json.Marshal(jr)
+}
+
+// recordValue is the value of a record: its only field or its Value field,
+// or else the value it encodes.
+func recordValue(record Record) interface{} {
+	v := reflect.ValueOf(record)
+	if v.Kind() == reflect.Struct {
+		if v.NumField() == 0 {
+			return nil
+		}
+		field := v.FieldByName("Value")
+		if v.NumField() == 1 {
+			field = v.Field(0)
+		}
+		switch field.Kind() {
+		case reflect.Bool:
+			return field.Bool()
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return field.Int()
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return field.Uint()
+		case reflect.Float32, reflect.Float64:
+			return field.Float()
+		case reflect.String:
+			return field.String()
+		}
+	}
+	var buf bytes.Buffer
+	record.Encode(&buf)
+	b := buf.Bytes()
+	if len(b) < 2 || len(b) < 2+int(b[1]&0x0f) || b[1]&0x0f == 0 {
+		return nil
+	}
+	return DecodeValue(b[1], b[2:2+int(b[1]&0x0f)]).Interface()
+}
+
+// UnmarshalRecord decodes the JSON form of a record sent by or to a
+// manufacturer/product, with the decoder registered for its parameter.
+func UnmarshalRecord(manuId, prodId byte, data []byte) (Record, error) {
+	var jr jsonRecord
+	if err := json.Unmarshal(data, &jr); err != nil {
+		return nil, err
+	}
+	return jr.record(manuId, prodId)
+}
+
+func (jr jsonRecord) record(manuId, prodId byte) (Record, error) {
+	var record Record
+	if jr.Type == "UnhandledRecord" {
+		var s string
+		if err := json.Unmarshal(jr.Value, &s); err != nil {
+			return nil, err
+		}
+		raw, err := hex.DecodeString(s)
+		if err != nil {
+			return nil, err
+		}
+		var encoding byte
+		if jr.Encoding != nil {
+			encoding = *jr.Encoding
+		}
+		record = NewUnhandledRecord(jr.Param, Value{encoding, raw})
+	} else {
+		value, err := jsonValue(parameter(jr.Param).Encoding, jr.Value)
+		if err != nil {
+			return nil, err
+		}
+		record, err = decodeRecord(manuId, prodId, jr.Param, value.TypeDesc(), value.Raw)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if name := reflect.TypeOf(record).Name(); jr.Type != "" && jr.Type != name {
+		return nil, fmt.Errorf("Record type %s, parameter %02x is %s", jr.Type, jr.Param, name)
+	}
+	return record, nil
+}
+
+// unmarshalRecordInto decodes a record into target, a pointer to a record
+// type, defaulting the parameter to that of the type.
+func unmarshalRecordInto(data []byte, target Record) error {
+	v := reflect.ValueOf(target).Elem()
+	jr := jsonRecord{Param: RecordParameter(v.Interface().(Record)).ID}
+	if err := json.Unmarshal(data, &jr); err != nil {
+		return err
+	}
+	record, err := jr.record(0, 0)
+	if err != nil {
+		return err
+	}
+	if reflect.TypeOf(record) != v.Type() {
+		return fmt.Errorf("Parameter %02x is %T, not %s", jr.Param, record, v.Type().Name())
+	}
+	v.Set(reflect.ValueOf(record))
+	return nil
+}
+
+// The records marshal to and from JSON with MarshalRecord and UnmarshalRecord,
+// decoding with the records registered for all products.
+
+// jsonValue is a JSON value in the given encoding, or as IEEE754 if the
+// encoding cannot hold the number exactly.
+func jsonValue(encoding byte, data json.RawMessage) (Value, error) {
+	if len(data) == 0 {
+		return Value{encoding, nil}, nil
+	}
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	var x interface{}
+	if err := d.Decode(&x); err != nil {
+		return Value{}, err
+	}
+	switch t := x.(type) {
+	case string:
+		return StringValue(t), nil
+	case bool:
+		return NewValue(ENC_UINT, t)
+	case json.Number:
+		if u, err := strconv.ParseUint(string(t), 10, 64); err == nil {
+			if v, err := NewValue(encoding, u); err == nil && v.Uint64() == u {
+				return v, nil
+			}
+			return UintValue(u), nil
+		}
+		if i, err := strconv.ParseInt(string(t), 10, 64); err == nil {
+			if v, err := NewValue(encoding, i); err == nil && v.Int64() == i {
+				return v, nil
+			}
+			return IntValue(i), nil
+		}
+		f, err := t.Float64()
+		if err != nil {
+			return Value{}, err
+		}
+		if v, err := NewValue(encoding, f); err == nil && v.Float64() == f {
+			return v, nil
+		}
+		return FloatValue(f), nil
+	}
+	return Value{}, fmt.Errorf("Unsupported value %s", data)
+}
+
+func (j Join) MarshalJSON() ([]byte, error)     { return MarshalRecord(j) }
+func (j *Join) UnmarshalJSON(data []byte) error { return unmarshalRecordInto(data, j) }
+
+func (t Temperature) MarshalJSON() ([]byte, error)     { return MarshalRecord(t) }
+func (t *Temperature) UnmarshalJSON(data []byte) error { return unmarshalRecordInto(data, t) }
+
+func (s SetTemperature) MarshalJSON() ([]byte, error)     { return MarshalRecord(s) }
+func (s *SetTemperature) UnmarshalJSON(data []byte) error { return unmarshalRecordInto(data, s) }
+
+func (v Voltage) MarshalJSON() ([]byte, error)     { return MarshalRecord(v) }
+func (v *Voltage) UnmarshalJSON(data []byte) error { return unmarshalRecordInto(data, v) }
+
+func (d Diagnostics) MarshalJSON() ([]byte, error)     { return MarshalRecord(d) }
+func (d *Diagnostics) UnmarshalJSON(data []byte) error { return unmarshalRecordInto(data, d) }
+
+func (u UnhandledRecord) MarshalJSON() ([]byte, error)     { return MarshalRecord(u) }
+func (u *UnhandledRecord) UnmarshalJSON(data []byte) error { return unmarshalRecordInto(data, u) }
+
+func (i Identify) MarshalJSON() ([]byte, error)     { return MarshalRecord(i) }
+func (i *Identify) UnmarshalJSON(data []byte) error { return unmarshalRecordInto(data, i) }
+
+func (j JoinReport) MarshalJSON() ([]byte, error)     { return MarshalRecord(j) }
+func (j *JoinReport) UnmarshalJSON(data []byte) error { return unmarshalRecordInto(data, j) }
+
+func (e ExerciseValve) MarshalJSON() ([]byte, error)     { return MarshalRecord(e) }
+func (e *ExerciseValve) UnmarshalJSON(data []byte) error { return unmarshalRecordInto(data, e) }
+
+func (r ReportInterval) MarshalJSON() ([]byte, error)     { return MarshalRecord(r) }
+func (r *ReportInterval) UnmarshalJSON(data []byte) error { return unmarshalRecordInto(data, r) }
+
+func (s SetValveState) MarshalJSON() ([]byte, error)     { return MarshalRecord(s) }
+func (s *SetValveState) UnmarshalJSON(data []byte) error { return unmarshalRecordInto(data, s) }
+
+func (s SetPowerMode) MarshalJSON() ([]byte, error)     { return MarshalRecord(s) }
+func (s *SetPowerMode) UnmarshalJSON(data []byte) error { return unmarshalRecordInto(data, s) }
+
+func (a ActuateSwitch) MarshalJSON() ([]byte, error)     { return MarshalRecord(a) }
+func (a *ActuateSwitch) UnmarshalJSON(data []byte) error { return unmarshalRecordInto(data, a) }
+
+func (s SourceSelector) MarshalJSON() ([]byte, error)     { return MarshalRecord(s) }
+func (s *SourceSelector) UnmarshalJSON(data []byte) error { return unmarshalRecordInto(data, s) }
+
+func (g GlobalRead) MarshalJSON() ([]byte, error)     { return MarshalRecord(g) }
+func (g *GlobalRead) UnmarshalJSON(data []byte) error { return unmarshalRecordInto(data, g) }
+
+func (t Test) MarshalJSON() ([]byte, error)     { return MarshalRecord(t) }
+func (t *Test) UnmarshalJSON(data []byte) error { return unmarshalRecordInto(data, t) }
+
+func (a Alarm) MarshalJSON() ([]byte, error)     { return MarshalRecord(a) }
+func (a *Alarm) UnmarshalJSON(data []byte) error { return unmarshalRecordInto(data, a) }
+
+func (d DebugOutput) MarshalJSON() ([]byte, error)     { return MarshalRecord(d) }
+func (d *DebugOutput) UnmarshalJSON(data []byte) error { return unmarshalRecordInto(data, d) }
+
+func (b BatteryVoltage) MarshalJSON() ([]byte, error)     { return MarshalRecord(b) }
+func (b *BatteryVoltage) UnmarshalJSON(data []byte) error { return unmarshalRecordInto(data, b) }
+
+func (w WaterDetector) MarshalJSON() ([]byte, error)     { return MarshalRecord(w) }
+func (w *WaterDetector) UnmarshalJSON(data []byte) error { return unmarshalRecordInto(data, w) }
+
+func (d DoorSensor) MarshalJSON() ([]byte, error)     { return MarshalRecord(d) }
+func (d *DoorSensor) UnmarshalJSON(data []byte) error { return unmarshalRecordInto(data, d) }
+
+func (f Frequency) MarshalJSON() ([]byte, error)     { return MarshalRecord(f) }
+func (f *Frequency) UnmarshalJSON(data []byte) error { return unmarshalRecordInto(data, f) }
+
+func (h Humidity) MarshalJSON() ([]byte, error)     { return MarshalRecord(h) }
+func (h *Humidity) UnmarshalJSON(data []byte) error { return unmarshalRecordInto(data, h) }
+
+func (c Current) MarshalJSON() ([]byte, error)     { return MarshalRecord(c) }
+func (c *Current) UnmarshalJSON(data []byte) error { return unmarshalRecordInto(data, c) }
+
+func (l LightLevel) MarshalJSON() ([]byte, error)     { return MarshalRecord(l) }
+func (l *LightLevel) UnmarshalJSON(data []byte) error { return unmarshalRecordInto(data, l) }
+
+func (m MotionDetector) MarshalJSON() ([]byte, error)     { return MarshalRecord(m) }
+func (m *MotionDetector) UnmarshalJSON(data []byte) error { return unmarshalRecordInto(data, m) }
+
+func (p PowerFactor) MarshalJSON() ([]byte, error)     { return MarshalRecord(p) }
+func (p *PowerFactor) UnmarshalJSON(data []byte) error { return unmarshalRecordInto(data, p) }
+
+func (o Occupancy) MarshalJSON() ([]byte, error)     { return MarshalRecord(o) }
+func (o *Occupancy) UnmarshalJSON(data []byte) error { return unmarshalRecordInto(data, o) }
+
+func (r RealPower) MarshalJSON() ([]byte, error)     { return MarshalRecord(r) }
+func (r *RealPower) UnmarshalJSON(data []byte) error { return unmarshalRecordInto(data, r) }
+
+func (r ReactivePower) MarshalJSON() ([]byte, error)     { return MarshalRecord(r) }
+func (r *ReactivePower) UnmarshalJSON(data []byte) error { return unmarshalRecordInto(data, r) }
+
+func (r RotationSpeed) MarshalJSON() ([]byte, error)     { return MarshalRecord(r) }
+func (r *RotationSpeed) UnmarshalJSON(data []byte) error { return unmarshalRecordInto(data, r) }
+
+func (s SwitchState) MarshalJSON() ([]byte, error)     { return MarshalRecord(s) }
+func (s *SwitchState) UnmarshalJSON(data []byte) error { return unmarshalRecordInto(data, s) }
+
+func (w WaterFlowRate) MarshalJSON() ([]byte, error)     { return MarshalRecord(w) }
+func (w *WaterFlowRate) UnmarshalJSON(data []byte) error { return unmarshalRecordInto(data, w) }
+
+func (w WaterPressure) MarshalJSON() ([]byte, error)     { return MarshalRecord(w) }
+func (w *WaterPressure) UnmarshalJSON(data []byte) error { return unmarshalRecordInto(data, w) }
+
+func (p Phase1Power) MarshalJSON() ([]byte, error)     { return MarshalRecord(p) }
+func (p *Phase1Power) UnmarshalJSON(data []byte) error { return unmarshalRecordInto(data, p) }
+
+func (p Phase2Power) MarshalJSON() ([]byte, error)     { return MarshalRecord(p) }
+func (p *Phase2Power) UnmarshalJSON(data []byte) error { return unmarshalRecordInto(data, p) }
+
+func (p Phase3Power) MarshalJSON() ([]byte, error)     { return MarshalRecord(p) }
+func (p *Phase3Power) UnmarshalJSON(data []byte) error { return unmarshalRecordInto(data, p) }
+
+func (t ThreePhasePower) MarshalJSON() ([]byte, error)     { return MarshalRecord(t) }
+func (t *ThreePhasePower) UnmarshalJSON(data []byte) error { return unmarshalRecordInto(data, t) }
+
+type jsonMessage struct {
+	ManuId       byte              `json:"manu_id"`
+	ProdId       byte              `json:"prod_id"`
+	SensorId     string            `json:"sensor_id"`
+	EncryptionId byte              `json:"encryption_id,omitempty"`
+	Pip          uint16            `json:"pip,omitempty"`
+	Replay       bool              `json:"replay,omitempty"`
+	Records      []json.RawMessage `json:"records"`
+}
+
+func (m *Message) MarshalJSON() ([]byte, error) {
+	jm := jsonMessage{
+		ManuId:       m.ManuId,
+		ProdId:       m.ProdId,
+		SensorId:     fmt.Sprintf("%06x", m.SensorId),
+		EncryptionId: m.EncryptionId,
+		Pip:          m.Pip,
+		Replay:       m.Replay,
+		Records:      []json.RawMessage{},
+	}
+	for _, record := range m.Records {
+		b, err := MarshalRecord(record)
+		if err != nil {
+			return nil, err
+		}
+		jm.Records = append(jm.Records, b)
+	}
+	return json.Marshal(jm)
+}
+
+func (m *Message) UnmarshalJSON(data []byte) error {
+	var jm jsonMessage
+	if err := json.Unmarshal(data, &jm); err != nil {
+		return err
+	}
+	sensorId, err := strconv.ParseUint(jm.SensorId, 16, 24)
+	if err != nil {
+		return fmt.Errorf("Invalid sensor_id %q", jm.SensorId)
+	}
+	message := Message{
+		ManuId:       jm.ManuId,
+		ProdId:       jm.ProdId,
+		SensorId:     uint32(sensorId),
+		EncryptionId: jm.EncryptionId,
+		Pip:          jm.Pip,
+		Replay:       jm.Replay,
+	}
+	for _, b := range jm.Records {
+		record, err := UnmarshalRecord(jm.ManuId, jm.ProdId, b)
+		if err != nil {
+			return err
+		}
+		message.Records = append(message.Records, record)
+	}
+	*m = message
+	return nil
+}
+
+type jsonQueuedCommand struct {
+	ID       uint64          `json:"id"`
+	SensorId string          `json:"sensor_id"`
+	Record   json.RawMessage `json:"record"`
+	Queued   time.Time       `json:"queued"`
+	Expires  time.Time       `json:"expires"`
+	Attempts int             `json:"attempts"`
+	Sent     time.Time       `json:"sent"`
+}
+
+func (c QueuedCommand) MarshalJSON() ([]byte, error) {
+	record, err := MarshalRecord(c.Record)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(jsonQueuedCommand{
+		ID:       c.ID,
+		SensorId: fmt.Sprintf("%06x", c.SensorId),
+		Record:   record,
+		Queued:   c.Queued,
+		Expires:  c.Expires,
+		Attempts: c.Attempts,
+		Sent:     c.Sent,
+	})
+}
+
+// UnmarshalJSON decodes a command with the records registered for all
+// products.
+func (c *QueuedCommand) UnmarshalJSON(data []byte) error {
+	var jc jsonQueuedCommand
+	if err := json.Unmarshal(data, &jc); err != nil {
+		return err
+	}
+	sensorId, err := strconv.ParseUint(jc.SensorId, 16, 24)
+	if err != nil {
+		return fmt.Errorf("Invalid sensor_id %q", jc.SensorId)
+	}
+	record, err := UnmarshalRecord(0, 0, jc.Record)
+	if err != nil {
+		return err
+	}
+	*c = QueuedCommand{
+		ID:       jc.ID,
+		SensorId: uint32(sensorId),
+		Record:   record,
+		Queued:   jc.Queued,
+		Expires:  jc.Expires,
+		Attempts: jc.Attempts,
+		Sent:     jc.Sent,
+	}
+	return nil
+}
+
+type jsonCommandResult struct {
+	Command QueuedCommand `json:"command"`
+	Err     string        `json:"error,omitempty"`
+}
+
+// commandErrors are the errors a CommandResult is read back as, by message.
+var commandErrors = []error{ErrNotConfirmed, ErrCommandExpired, ErrCommandReplaced, ErrCommandCancelled}
+
+func (r CommandResult) MarshalJSON() ([]byte, error) {
+	jr := jsonCommandResult{Command: r.Command}
+	if r.Err != nil {
+		jr.Err = r.Err.Error()
+	}
+	return json.Marshal(jr)
+}
+
+// UnmarshalJSON decodes a result, with the queue's errors such as
+// ErrNotConfirmed read back as themselves and others by their message.
+func (r *CommandResult) UnmarshalJSON(data []byte) error {
+	var jr jsonCommandResult
+	if err := json.Unmarshal(data, &jr); err != nil {
+		return err
+	}
+	result := CommandResult{Command: jr.Command}
+	if jr.Err != "" {
+		result.Err = errors.New(jr.Err)
+		for _, err := range commandErrors {
+			if err.Error() == jr.Err {
+				result.Err = err
+			}
+		}
+	}
+	*r = result
+	return nil
+}
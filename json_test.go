@@ -0,0 +1,130 @@
+package ener314
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessageJSONRoundTrip(t *testing.T) {
+	message := &Message{
+		ManuId:   energenieManuId,
+		ProdId:   eTRVProdId,
+		SensorId: 0x00097f,
+		Records: []Record{
+			Join{}, Temperature{17.5}, SetTemperature{21}, Voltage{3.1}, Diagnostics{512},
+			UnhandledRecord{0x50, 0x12, []byte{0x12, 0x80}}, NewUnhandledRecord(0xa7, UintValue(3)),
+			Identify{}, JoinReport{}, ExerciseValve{}, ReportInterval{300},
+			SetValveState{VALVE_STATE_CLOSED}, SetPowerMode{POWER_MODE_LOW},
+			ActuateSwitch{true}, Alarm{1}, BatteryVoltage{2.9}, WaterDetector{true}, DoorSensor{true},
+			Frequency{50.1}, Humidity{45}, Current{1.5}, LightLevel{300},
+			MotionDetector{true}, PowerFactor{0.9}, Occupancy{true}, RealPower{-20},
+			ReactivePower{5}, RotationSpeed{1200}, SwitchState{true}, WaterFlowRate{4},
+			WaterPressure{101000}, Phase3Power{1000}, Test{5}, SourceSelector{2}, GlobalRead{}, ThreePhasePower{3000},
+		},
+		EncryptionId: 0xf2,
+	}
+	data, err := json.Marshal(message)
+	assert.NoError(t, err)
+
+	var decoded Message
+	assert.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, message, &decoded)
+}
+
+func TestRecordJSON(t *testing.T) {
+	record, err := UnmarshalRecord(energenieManuId, eTRVProdId, []byte(`{"type":"Temperature","param":116,"value":18.5}`))
+	assert.NoError(t, err)
+	assert.Equal(t, Temperature{18.5}, record)
+
+	record, err = UnmarshalRecord(energenieManuId, eTRVProdId, []byte(`{"param":116,"value":18.5}`))
+	assert.NoError(t, err)
+	assert.Equal(t, Temperature{18.5}, record)
+
+	for _, data := range []string{
+		`{"type":"Temperature","param":118,"value":3}`,
+		`{"type":"Phase1Power","param":122,"value":3}`,
+		`{"type":"Phase1Power","value":3}`,
+		`{"type":"Temperature","param":116,"value":[]}`,
+		`{"type":"UnhandledRecord","param":80,"value":"xyz"}`,
+	} {
+		_, err := UnmarshalRecord(energenieManuId, eTRVProdId, []byte(data))
+		assert.Error(t, err, data)
+	}
+
+	var message Message
+	assert.Error(t, json.Unmarshal([]byte(`{"sensor_id":"00097f","records":[{"type":"Bogus"}]}`), &message))
+	assert.Error(t, json.Unmarshal([]byte(`{"sensor_id":"xyz","records":[]}`), &message))
+}
+
+func TestTypedRecordJSON(t *testing.T) {
+	data, err := json.Marshal(Temperature{18})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"type":"Temperature","param":116,"value":18,"unit":"°C"}`, string(data))
+
+	var temp Temperature
+	assert.NoError(t, json.Unmarshal(data, &temp))
+	assert.Equal(t, Temperature{18}, temp)
+	// the parameter defaults to that of the type
+	assert.NoError(t, json.Unmarshal([]byte(`{"value":300}`), new(ReportInterval)))
+	assert.Error(t, json.Unmarshal(data, new(Voltage)))
+	assert.Equal(t, ErrValueRange, json.Unmarshal([]byte(`{"param":210,"value":70000}`), new(ReportInterval)))
+}
+
+func TestQueuedCommandJSON(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	result := CommandResult{
+		Command: QueuedCommand{ID: 7, SensorId: 0x00097f, Record: SetValveState{VALVE_STATE_CLOSED}, Queued: now, Attempts: 3, Sent: now},
+		Err:     ErrNotConfirmed,
+	}
+	data, err := json.Marshal(result)
+	assert.NoError(t, err)
+
+	var decoded CommandResult
+	assert.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, result, decoded)
+
+	data, err = json.Marshal(CommandResult{Command: result.Command})
+	assert.NoError(t, err)
+	assert.NoError(t, json.Unmarshal(data, &decoded))
+	assert.NoError(t, decoded.Err)
+}
+
+// levelRecord is a record only known through RegisterProductRecord.
+type levelRecord struct {
+	level uint16
+}
+
+func (l levelRecord) String() string { return fmt.Sprintf("Level{%d}", l.level) }
+
+func (l levelRecord) Encode(buf ByteAndBytesWriter) {
+	v := UintValue(uint64(l.level))
+	buf.Write(append([]byte{0x50, v.TypeDesc()}, v.Raw...))
+}
+
+func TestRegisteredRecordJSON(t *testing.T) {
+	RegisterProductRecord(0x7f, 0x01, 0x50, UintDecoder(func(v uint16) Record { return levelRecord{v} }), nil)
+	t.Cleanup(func() { UnregisterProductRecord(0x7f, 0x01, 0x50) })
+
+	message := &Message{ManuId: 0x7f, ProdId: 0x01, SensorId: 0x000001, Records: []Record{levelRecord{300}}}
+	data, err := json.Marshal(message)
+	assert.NoError(t, err)
+
+	var decoded Message
+	assert.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, message, &decoded)
+}
+
+func ExampleMessage_MarshalJSON() {
+	message := &Message{
+		ManuId: 4, ProdId: 3, SensorId: 0x00097f,
+		Records: []Record{Temperature{17.5}, SetValveState{VALVE_STATE_AUTO}, Identify{}},
+	}
+	data, _ := json.Marshal(message)
+	fmt.Println(string(data))
+	// Output:
+	// {"manu_id":4,"prod_id":3,"sensor_id":"00097f","records":[{"type":"Temperature","param":116,"value":17.5,"unit":"°C"},{"type":"SetValveState","param":165,"value":2},{"type":"Identify","param":191}]}
+}
@@ -1,6 +1,10 @@
 package ener314
 
-import "log"
+import (
+	"fmt"
+	"log"
+	"strings"
+)
 
 const (
 	LOG_TRACE = iota
@@ -11,18 +15,49 @@ const (
 
 var logLevel = LOG_INFO
 
+// SetLevel sets the level DefaultLogger logs at and above.
 func SetLevel(level int) {
 	logLevel = level
 }
 
-func logs(level int, msg ...interface{}) {
-	if level >= logLevel {
-		log.Println(msg...)
+// Logger receives log output. fields are alternating keys and values, such as
+// "sensor", "00097f", as for log/slog.
+type Logger interface {
+	Log(level int, msg string, fields ...interface{})
+}
+
+// DefaultLogger logs to the standard log package, at or above the level set
+// with SetLevel. It is used by Devices without a Logger.
+var DefaultLogger Logger = stdLogger{}
+
+type stdLogger struct{}
+
+func (stdLogger) Log(level int, msg string, fields ...interface{}) {
+	if level < logLevel {
+		return
 	}
+	var b strings.Builder
+	b.WriteString(msg)
+	for i := 0; i+1 < len(fields); i += 2 {
+		fmt.Fprintf(&b, " %v=%v", fields[i], fields[i+1])
+	}
+	log.Println(b.String())
+}
+
+func logs(level int, msg ...interface{}) {
+	DefaultLogger.Log(level, strings.TrimSuffix(fmt.Sprintln(msg...), "\n"))
+}
+
+// sensorField formats a sensor id as a log field value.
+func sensorField(sensorId uint32) string {
+	return fmt.Sprintf("%06x", sensorId)
 }
 
-func logf(level int, fmt string, msg ...interface{}) {
-	if level >= logLevel {
-		log.Printf(fmt, msg...)
+// log logs to the Device's Logger.
+func (d *Device) log(level int, msg string, fields ...interface{}) {
+	logger := d.Logger
+	if logger == nil {
+		logger = DefaultLogger
 	}
+	logger.Log(level, msg, fields...)
 }
@@ -0,0 +1,80 @@
+package ener314
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type logEntry struct {
+	level  int
+	msg    string
+	fields []interface{}
+}
+
+// recordingLogger is a Logger for tests.
+type recordingLogger struct {
+	mu      sync.Mutex
+	entries []logEntry
+}
+
+func (l *recordingLogger) Log(level int, msg string, fields ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, logEntry{level, msg, fields})
+}
+
+func (l *recordingLogger) find(msg string) []logEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var ret []logEntry
+	for _, e := range l.entries {
+		if e.msg == msg {
+			ret = append(ret, e)
+		}
+	}
+	return ret
+}
+
+func TestDeviceLogger(t *testing.T) {
+	logger := &recordingLogger{}
+	dev := NewDevice()
+	dev.Logger = logger
+	dev.Radio = &fakeRadio{}
+
+	assert.NoError(t, dev.Identify(0x00097f))
+	assert.Empty(t, logger.find("Packet"))
+	sent := logger.find("Sent")
+	if assert.Len(t, sent, 1) {
+		assert.Equal(t, LOG_TRACE, sent[0].level)
+		assert.Equal(t, []interface{}{"dir", "tx", "sensor", "00097f", "message", sent[0].fields[5]}, sent[0].fields)
+	}
+
+	dev.TracePackets = true
+	dev.PipSource = NewSequencePipSource(0x1234)
+	assert.NoError(t, dev.Identify(0x00097f))
+	packets := logger.find("Packet")
+	if assert.Len(t, packets, 1) {
+		assert.Equal(t, []interface{}{"dir", "tx", "packet", "0403123400097fbf0000ec29"}, packets[0].fields)
+	}
+
+	var rangeErr error = dev.TargetTemperature(0x00097f, 40)
+	failed := logger.find("Sending command failed")
+	if assert.Len(t, failed, 1) {
+		assert.Equal(t, LOG_ERROR, failed[0].level)
+		assert.Equal(t, []interface{}{"dir", "tx", "sensor", "00097f", "error", rangeErr}, failed[0].fields)
+	}
+}
+
+func TestReportIntervalLog(t *testing.T) {
+	logger := &recordingLogger{}
+	dev := NewDevice()
+	dev.Logger = logger
+	dev.Radio = &fakeRadio{}
+
+	assert.Error(t, dev.ReportInterval(0x00097f, 0))
+	assert.Empty(t, logger.find("Setting report interval"))
+	assert.NoError(t, dev.ReportInterval(0x00097f, 300))
+	assert.Len(t, logger.find("Setting report interval"), 1)
+}
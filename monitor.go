@@ -0,0 +1,81 @@
+package ener314
+
+import (
+	"sync"
+	"time"
+)
+
+// A handle is a typed view of one device, kept up to date from its reports.
+type handle interface {
+	update(msg *Message, now time.Time)
+}
+
+// handleKey identifies the handle for a sensor of an Energenie product, so
+// handles of different products never replace each other.
+type handleKey struct {
+	prodId   byte
+	sensorId uint32
+}
+
+// MonitorReadings are the last readings reported by a monitor plug.
+type MonitorReadings struct {
+	RealPower     float64 // W
+	ReactivePower float64 // VAR
+	Voltage       float64 // V
+	Frequency     float64 // Hz
+	Updated       time.Time
+}
+
+// Monitor is a MIHO004 Monitor plug.
+type Monitor struct {
+	SensorId uint32
+
+	mu       sync.Mutex
+	readings MonitorReadings
+}
+
+// Monitor returns the handle for the MIHO004 Monitor plug with the given id.
+func (d *Device) Monitor(sensorId uint32) *Monitor {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if m, ok := d.handles[handleKey{monitorProdId, sensorId}].(*Monitor); ok {
+		return m
+	}
+	m := &Monitor{SensorId: sensorId}
+	d.addHandle(handleKey{monitorProdId, sensorId}, m)
+	return m
+}
+
+// Readings returns the last reported readings.
+func (m *Monitor) Readings() MonitorReadings {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.readings
+}
+
+func (m *Monitor) update(msg *Message, now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, record := range msg.Records {
+		m.readings.update(record, now)
+	}
+}
+
+// update applies a reading from a record, returning false if the record is
+// not a reading.
+func (r *MonitorReadings) update(record Record, now time.Time) bool {
+	switch t := record.(type) {
+	case RealPower:
+		r.RealPower = t.Value
+	case ReactivePower:
+		r.ReactivePower = t.Value
+	case Voltage:
+		r.Voltage = t.Value
+	case Frequency:
+		r.Frequency = t.Value
+	default:
+		return false
+	}
+	r.Updated = now
+	return true
+}
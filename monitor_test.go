@@ -0,0 +1,29 @@
+package ener314
+
+import (
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMonitorUpdate(t *testing.T) {
+	dev := NewDevice()
+	monitor := dev.Monitor(0x000123)
+	assert.Same(t, monitor, dev.Monitor(0x000123))
+
+	packet, _ := hex.DecodeString("04010000000123708200647182000a7601f066223200008219")
+	message, err := decodePacket(packet)
+	assert.NoError(t, err)
+
+	now := time.Now()
+	monitor.update(message, now)
+	assert.Equal(t, MonitorReadings{
+		RealPower:     100,
+		ReactivePower: 10,
+		Voltage:       240,
+		Frequency:     50,
+		Updated:       now,
+	}, monitor.Readings())
+}
@@ -5,15 +5,18 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
-	"math/rand"
-	"strconv"
+	"math"
 )
 
 const (
 	/* OpenThings definitions */
 	energenieManuId = 0x04 // Energenie Manufacturer Id
 	eTRVProdId      = 0x3  // Product ID for eTRV
-	encryptId       = 0xf2 // Encryption ID for eTRV
+	monitorProdId   = 0x1  // Product ID for MIHO004 Monitor
+	adapterProdId   = 0x2  // Product ID for MIHO005 Adapter Plus
+	energyProdId    = 0x5  // Product ID for MIHO006 House monitor
+	motionProdId    = 0xC  // Product ID for MIHO032 Motion sensor
+	openProdId      = 0xD  // Product ID for MIHO033 Open sensor
 
 	OT_JOIN_RESP = 0x6A
 	OT_JOIN_CMD  = 0xEA
@@ -27,6 +30,25 @@ const (
 	OT_TEST       = 0xAA
 	OT_SW_STATE   = 0x73
 
+	OT_ALARM           = 0x21
+	OT_DEBUG_OUTPUT    = 0x2D
+	OT_SOURCE_SELECTOR = 0xC0
+	OT_WATER_DETECTOR  = 0x41
+	OT_GLOBAL_READ     = 0xE1
+	OT_DOOR_SENSOR     = 0x64
+	OT_HUMIDITY        = 0x68
+	OT_LIGHT_LEVEL     = 0x6C
+	OT_MOTION_DETECTOR = 0x6D
+	OT_POWER_FACTOR    = 0x6E
+	OT_OCCUPANCY       = 0x6F
+	OT_ROTATION_SPEED  = 0x72
+	OT_WATER_FLOW_RATE = 0x77
+	OT_WATER_PRESSURE  = 0x78
+	OT_PHASE1_POWER    = 0x79
+	OT_PHASE2_POWER    = 0x7A
+	OT_PHASE3_POWER    = 0x7B
+	OT_3PHASE_POWER    = 0x7C
+
 	OT_TEMP_SET    = 0xf4 /* Send new target temperature to driver board */
 	OT_TEMP_REPORT = 0x74 /* Send externally read room temperature to motor board */
 
@@ -117,6 +139,15 @@ const (
 	VALVE_STATE_AUTO   ValveState = 2
 )
 
+var valveStateNames = []string{"open", "closed", "auto"}
+
+func (v ValveState) String() string {
+	if v >= 0 && int(v) < len(valveStateNames) {
+		return valveStateNames[v]
+	}
+	return fmt.Sprintf("ValveState(%d)", int(v))
+}
+
 type PowerMode int
 
 const (
@@ -125,11 +156,35 @@ const (
 	POWER_MODE_LOW    PowerMode = 1
 )
 
+var powerModeNames = []string{"normal", "low"}
+
+func (m PowerMode) String() string {
+	if m >= 0 && int(m) < len(powerModeNames) {
+		return powerModeNames[m]
+	}
+	return fmt.Sprintf("PowerMode(%d)", int(m))
+}
+
 type Message struct {
 	ManuId   byte
 	ProdId   byte
 	SensorId uint32
 	Records  []Record
+	// EncryptionId the message was received with. If set when sending it
+	// overrides the id registered for the product.
+	EncryptionId byte
+	// Pip is the encryption nonce the message was received with.
+	Pip uint16
+	// Replay is set when a received message reuses a PIP seen from the sensor
+	// outside the duplicate window, see Device.DetectReplays.
+	Replay bool
+}
+
+func (m *Message) encryptionId() byte {
+	if m.EncryptionId != 0 {
+		return m.EncryptionId
+	}
+	return EncryptionId(m.ManuId, m.ProdId)
 }
 
 func (m *Message) String() string {
@@ -157,92 +212,69 @@ func decrypt(pid, pip uint16, data []byte) {
 	}
 }
 
-func decodeFixedPoint(value []byte, mantissa uint, signed bool) float64 {
-	var ret float64
-	sign := false
-	if signed && len(value) > 0 && (value[0]&0x80 != 0) {
-		value[0] = value[0] & 0x7f
-		sign = true
-	}
-
-	for _, b := range value {
-		ret = ret*256 + float64(b)
-	}
-	div := 1 << mantissa
-	if sign {
-		ret = -ret
-	}
-	return ret / float64(div)
+func decodeFloat64(typeDesc byte, value []byte) float64 {
+	return Value{typeDesc >> 4, value}.Float64()
 }
 
-func decodeFloat64(typeDesc byte, value []byte) float64 {
-	switch typeDesc >> 4 {
-	case ENC_UINT: // Unsigned x.0 normal integer
-		return decodeFixedPoint(value, 0, false)
-	case ENC_UFPp4: // Unsigned x.4 fixed point integer
-		return decodeFixedPoint(value, 4, false)
-	case ENC_UFPp8: // Unsigned x.8 fixed point integer
-		return decodeFixedPoint(value, 8, false)
-	case ENC_UFPp12: // Unsigned x.12 fixed point integer
-		return decodeFixedPoint(value, 12, false)
-	case ENC_UFPp16: // Unsigned x.16 fixed point integer
-		return decodeFixedPoint(value, 16, false)
-	case ENC_UFPp20: // Unsigned x.20 fixed point integer
-		return decodeFixedPoint(value, 20, false)
-	case ENC_UFPp24: // Unsigned x.24 fixed point integer
-		return decodeFixedPoint(value, 24, false)
-	case ENC_CHARS: // Characters
-		f64, _ := strconv.ParseFloat(string(value), 32)
-		return f64
-	case ENC_SINT: // Signed x.0 normal integer
-		return decodeFixedPoint(value, 0, true)
-	case ENC_SFPp8: // Signed x.8 fixed point integer
-		return decodeFixedPoint(value, 8, true)
-	case ENC_SFPp16: // Signed x.16 fixed point integer
-		return decodeFixedPoint(value, 16, true)
-	case ENC_SFPp24: // Signed x.24 fixed point integer
-		return decodeFixedPoint(value, 24, true)
-	case ENC_ENUM: // Enumeration
-		// Just treat as unsigned integer
-		return decodeFixedPoint(value, 0, false)
-	case ENC_RESV1, ENC_RESV2: // Reserved
-	case ENC_IEEE: // IEEE754-2008 floating point
-		// untesed - 32 or 64?
-		var ret float64
-		buf := bytes.NewReader(value)
-		binary.Read(buf, binary.LittleEndian, &ret)
-		return ret
+// decodeIEEE decodes a big endian IEEE754 value, single or double precision
+// according to its length.
+func decodeIEEE(value []byte) (float64, error) {
+	switch len(value) {
+	case 4:
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(value))), nil
+	case 8:
+		return math.Float64frombits(binary.BigEndian.Uint64(value)), nil
 	}
-	return 0
+	return 0, ErrUnsupportedLength
 }
 
-func decodeUint16(typeDesc byte, value []byte) uint16 {
-	switch typeDesc >> 4 {
-	case ENC_UINT: // Unsigned x.0 normal integer
-		var ret uint16
-		for _, b := range value {
-			ret = ret<<8 + uint16(b)
-		}
-		return ret
-	default:
-		// others unimplemented
-		return 0
+func cryptPacket(data []byte) {
+	if len(data) <= 4 {
+		return
 	}
+	cryptPacketId(data, EncryptionId(data[0], data[1]))
 }
 
-func cryptPacket(data []byte) {
+func cryptPacketId(data []byte, encryptionId byte) {
 	// reversable: encrypt is decrypt
 	if len(data) <= 4 {
 		return
 	}
 	pip := uint16(data[2])<<8 | uint16(data[3])
-	decrypt(encryptId, pip, data[4:])
+	decrypt(uint16(encryptionId), pip, data[4:])
 }
 
 var ErrShortPacket = errors.New("Short or corrupt packet")
 var ErrCRCFail = errors.New("CRC fail")
+var ErrUnsupportedLength = errors.New("Unsupported value length")
+var ErrNoEncoder = errors.New("No encoder registered for parameter")
+var ErrValueRange = errors.New("Value out of range for parameter")
+var ErrLengthMismatch = errors.New("Value length does not match its type descriptor")
+
+// DecodeError is a malformed record within a packet.
+type DecodeError struct {
+	Offset  int  // offset of the record within the packet
+	ParamId byte // parameter id of the record
+	Reason  string
+	Err     error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("%s at offset %d, parameter %02x: %s", e.Err, e.Offset, e.ParamId, e.Reason)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
 
 func decodePacket(data []byte) (*Message, error) {
+	return decodePacketLenient(data, false)
+}
+
+// decodePacketLenient decodes a decrypted packet. If lenient, a malformed
+// record returns the message decoded so far along with the error. A failed
+// CRC is always fatal.
+func decodePacketLenient(data []byte, lenient bool) (*Message, error) {
 	ln := len(data)
 	if ln < 10 {
 		// absolute minimum:
@@ -251,7 +283,7 @@ func decodePacket(data []byte) (*Message, error) {
 		// 3 sensor id
 		// 1 no records
 		// 2 crc
-		return nil, ErrShortPacket
+		return nil, &DecodeError{ln, 0, fmt.Sprintf("packet length %d below minimum 10", ln), ErrShortPacket}
 	}
 
 	// check CRC
@@ -265,6 +297,13 @@ func decodePacket(data []byte) (*Message, error) {
 		ManuId:   data[0],
 		ProdId:   data[1],
 		SensorId: uint32(data[4])<<16 | uint32(data[5])<<8 | uint32(data[6]),
+		Pip:      uint16(data[2])<<8 | uint16(data[3]),
+	}
+	fail := func(err *DecodeError) (*Message, error) {
+		if lenient {
+			return &message, err
+		}
+		return nil, err
 	}
 	// i + one byte + crc
 	for i := 7; true; i += 2 {
@@ -275,43 +314,24 @@ func decodePacket(data []byte) (*Message, error) {
 		}
 		if i >= ln-4 {
 			// at least [code] [typedesc] [crc] [crc]
-			return nil, ErrShortPacket
+			return fail(&DecodeError{i, paramId, "missing type descriptor", ErrShortPacket})
 		}
 
 		typeDesc := data[i+1]
 		dlen := typeDesc & 0x0f
 		if i+2+int(dlen)+2 >= ln {
 			// at least [code] [typedesc] [..variable..] [crc] [crc]
-			return nil, ErrShortPacket
+			return fail(&DecodeError{i, paramId, fmt.Sprintf("value length %d overruns packet", dlen), ErrShortPacket})
 		}
 
 		value := data[i+2 : i+2+int(dlen)]
-		i += int(dlen)
 
-		// value length check
-		switch paramId {
-		case OT_TEMP_REPORT, OT_VOLTAGE, OT_REPORT_DIAGNOSTICS:
-			if dlen == 0 {
-				return nil, ErrShortPacket
-			}
-		}
-
-		var record Record
-		switch paramId {
-		case OT_JOIN_CMD:
-			record = Join{}
-		case OT_TEMP_REPORT:
-			record = Temperature{decodeFloat64(typeDesc, value)}
-		case OT_TEMP_SET:
-			record = SetTemperature{decodeFloat64(typeDesc, value)}
-		case OT_VOLTAGE:
-			record = Voltage{decodeFloat64(typeDesc, value)}
-		case OT_REPORT_DIAGNOSTICS:
-			record = Diagnostics{decodeUint16(typeDesc, value)}
-		default:
-			record = UnhandledRecord{paramId, typeDesc, value}
+		record, err := decodeRecord(message.ManuId, message.ProdId, paramId, typeDesc, value)
+		if err != nil {
+			return fail(&DecodeError{i, paramId, "record decoder failed", err})
 		}
 		message.Records = append(message.Records, record)
+		i += int(dlen)
 	}
 	return &message, nil
 }
@@ -332,11 +352,30 @@ func calculateCRC(data []byte) uint16 {
 	return rem
 }
 
-func encryptData(data []byte) {
-	pip := uint16(rand.Uint32())
+func encryptData(data []byte, encryptionId byte, pip uint16) {
 	data[2] = byte(pip >> 8)
-	data[3] = byte(pip >> 8)
-	cryptPacket(data)
+	data[3] = byte(pip)
+	cryptPacketId(data, encryptionId)
+}
+
+// Encode encodes and encrypts a message for transmission, with a PIP from
+// pips (DefaultPipSource if nil).
+func Encode(message *Message, pips PipSource) []byte {
+	data, _ := encodeFrame(message, pips)
+	return data
+}
+
+// encodeFrame is Encode, also returning the packet before encryption.
+func encodeFrame(message *Message, pips PipSource) ([]byte, []byte) {
+	if pips == nil {
+		pips = DefaultPipSource
+	}
+	data := encodeMessage(message)
+	pip := pips.Pip()
+	plain := append([]byte(nil), data...)
+	plain[2], plain[3] = byte(pip>>8), byte(pip)
+	encryptData(data, message.encryptionId(), pip)
+	return data, plain
 }
 
 func encodeMessage(message *Message) []byte {
@@ -357,54 +396,23 @@ func encodeMessage(message *Message) []byte {
 	buf.WriteByte(0) // end of params
 
 	// only encrypted data is CRCed
-	crc := calculateCRC(buf.Bytes()[5:])
+	crc := calculateCRC(buf.Bytes()[4:])
 	buf.WriteByte(byte(crc >> 8))
 	buf.WriteByte(byte(crc))
 	return buf.Bytes()
 }
 
 func encodeInteger(encoding byte, value uint32) []byte {
-	var nbytes byte
-	for nbytes = 4; nbytes > 1; nbytes -= 1 {
-		if value>>((nbytes-1)*8) != 0 {
-			break
-		}
-	}
-
-	var buf bytes.Buffer
-	buf.WriteByte(encoding<<4 + nbytes)
-	// Big endian
-	for ; nbytes > 0; nbytes -= 1 {
-		b := byte(value >> ((nbytes - 1) * 8))
-		buf.WriteByte(b)
-	}
-	return buf.Bytes()
-}
-
-func encodeFixedPoint(encoding byte, value float64, mantissa uint) []byte {
-	// TODO: handle signed
-	e := 1 << mantissa
-	var encoded uint32 = uint32(value * float64(e))
-	return encodeInteger(encoding, encoded)
+	v := Value{encoding, unsignedBytes(uint64(value))}
+	return append([]byte{v.TypeDesc()}, v.Raw...)
 }
 
+// encodeFloat64 encodes value for a record. Records cannot fail to encode, so
+// negative values of unsigned parameters are sent as zero.
 func encodeFloat64(enc byte, value float64) []byte {
-	switch enc {
-	case ENC_UINT, ENC_UFPp4, ENC_UFPp8, ENC_UFPp12, ENC_UFPp16, ENC_UFPp20, ENC_UFPp24: // Unsigned x.n
-		return encodeFixedPoint(enc, value, 4*uint(enc))
-	case ENC_CHARS: // Characters
-		return []byte(fmt.Sprint(value))
-	case ENC_SINT, ENC_SFPp8, ENC_SFPp16, ENC_SFPp24: // Signed x.n
-		return encodeFixedPoint(enc, value, 8*uint(enc-ENC_SINT))
-	case ENC_ENUM: // Enumeration
-		// Just treat as unsigned integer
-		return encodeInteger(ENC_UINT, uint32(value))
-	case ENC_RESV1, ENC_RESV2: // Reserved
-	case ENC_IEEE: // IEEE754-2008 floating point
-		// untesed - 32 or 64?
-		var buf bytes.Buffer
-		binary.Write(&buf, binary.LittleEndian, value)
-		return buf.Bytes()
+	v, err := FixedValue(enc, value)
+	if err != nil {
+		v, _ = FixedValue(enc, 0)
 	}
-	return nil
+	return append([]byte{v.TypeDesc()}, v.Raw...)
 }
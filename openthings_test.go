@@ -2,6 +2,7 @@ package ener314
 
 import (
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"strings"
 	"testing"
@@ -49,6 +50,11 @@ var decodeFloat64Table = []struct {
 	{0xd1, []byte{0x34}, 0},
 	// 1110 Reserved
 	{0xe1, []byte{0x34}, 0},
+	// 1111 IEEE754-2008 floating point
+	{0xf4, []byte{0x42, 0x48, 0x00, 0x00}, 50},
+	{0xf4, []byte{0xbf, 0x40, 0x00, 0x00}, -0.75},
+	{0xf8, []byte{0x40, 0x49, 0x0f, 0xdb, 0x40, 0x00, 0x00, 0x00}, 50.123878479003906},
+	{0xf2, []byte{0x42, 0x48}, 0},
 }
 
 func TestDecodeFloat64(t *testing.T) {
@@ -76,6 +82,14 @@ var encodeFloat64Table = []struct {
 	{ENC_SFPp8, 10.5, []byte{0x92, 0x0a, 0x80}},
 	{ENC_SFPp8, 18.5, []byte{0x92, 0x12, 0x80}},
 	{ENC_SFPp8, 256.0, []byte{0x93, 0x01, 0x00, 0x00}},
+
+	// 0111 Characters, with a type descriptor like the other encodings
+	// (previously just "42")
+	{ENC_CHARS, 42, []byte{0x72, 0x34, 0x32}},
+
+	// 1111 IEEE754-2008 floating point
+	{ENC_IEEE, 50, []byte{0xf4, 0x42, 0x48, 0x00, 0x00}},
+	{ENC_IEEE, 0.1, []byte{0xf8, 0x3f, 0xb9, 0x99, 0x99, 0x99, 0x99, 0x99, 0x9a}},
 }
 
 func TestEncodeFloat64(t *testing.T) {
@@ -84,7 +98,7 @@ func TestEncodeFloat64(t *testing.T) {
 	}
 }
 
-func ExampleDecodePacketJoin() {
+func Example_decodePacketJoin() {
 	packet := []byte{0x04, 0x03, 0x04, 0x42, 0xd1, 0xf8, 0x17, 0x05, 0xd1, 0xd9, 0x0f, 0x30}
 	cryptPacket(packet)
 	message, _ := decodePacket(packet)
@@ -93,7 +107,7 @@ func ExampleDecodePacketJoin() {
 	// {ManuId:4 ProdId:3 SensorId:00097f Records:[Join]}
 }
 
-func ExampleDecodePacketVoltage() {
+func Example_decodePacketVoltage() {
 	packet := []byte{0x04, 0x03, 0x13, 0x04, 0x20, 0x3b, 0x19, 0xd5, 0x8c, 0xf1, 0x5f, 0xf1, 0xd3, 0x7b}
 	cryptPacket(packet)
 	message, _ := decodePacket(packet)
@@ -102,7 +116,7 @@ func ExampleDecodePacketVoltage() {
 	// {ManuId:4 ProdId:3 SensorId:00097f Records:[Voltage{3.121094}]}
 }
 
-func ExampleDecodePacketTemp() {
+func Example_decodePacketTemp() {
 	packet := []byte{0x04, 0x03, 0x0f, 0x42, 0x89, 0x00, 0x3a, 0x46, 0x9c, 0xa6, 0xe2, 0x35, 0x1f, 0xdc}
 	cryptPacket(packet)
 	message, _ := decodePacket(packet)
@@ -111,7 +125,7 @@ func ExampleDecodePacketTemp() {
 	// {ManuId:4 ProdId:3 SensorId:00097f Records:[Temperature{17.699219}]}
 }
 
-func ExampleDecodePacketDiagnostics() {
+func Example_decodePacketDiagnostics() {
 	packet, _ := hex.DecodeString("0403704d00097f2602020000ed6a")
 	message, _ := decodePacket(packet)
 	fmt.Println(message)
@@ -119,7 +133,21 @@ func ExampleDecodePacketDiagnostics() {
 	// {ManuId:4 ProdId:3 SensorId:00097f Records:[Diagnostics{512,[Valve exercise was successful]}]}
 }
 
-func ExampleCRCFailure() {
+func Example_decodePacketMonitor() {
+	packet, _ := hex.DecodeString("04010000000123708200647182000a7601f066223200008219")
+	message, _ := decodePacket(packet)
+	for _, record := range message.Records {
+		p := RecordParameter(record)
+		fmt.Println(p.Name, record, p.Unit)
+	}
+	// Output:
+	// REAL_POWER RealPower{100.000000} W
+	// REACTIVE_POWER ReactivePower{10.000000} VAR
+	// VOLTAGE Voltage{240.000000} V
+	// FREQUENCY Frequency{50.000000} Hz
+}
+
+func Example_cRCFailure() {
 	packet := []byte{0x04, 0x03, 0x04, 0x42, 0xd1, 0xf8, 0x17, 0x05, 0xd1, 0xd9, 0x0f, 0x31}
 	cryptPacket(packet)
 	_, err := decodePacket(packet)
@@ -162,7 +190,61 @@ func TestBadPackets(t *testing.T) {
 	}
 }
 
-func ExampleEncodeMessageJoin() {
+var lenientPacketsTable = []struct {
+	packet  string
+	offset  int
+	paramId byte
+	err     error
+}{
+	// voltage value overruns packet
+	{"0403000000097f74921280760501005bb8", 11, OT_VOLTAGE, ErrShortPacket},
+	// 2 byte IEEE754 frequency
+	{"0403000000097f7492128066f2128000980f", 11, OT_FREQUENCY, ErrUnsupportedLength},
+}
+
+func TestLenientDecode(t *testing.T) {
+	for _, tt := range lenientPacketsTable {
+		data, _ := hex.DecodeString(tt.packet)
+
+		message, err := decodePacket(data)
+		assert.Nil(t, message)
+		assert.True(t, errors.Is(err, tt.err))
+
+		message, err = decodePacketLenient(data, true)
+		var decodeErr *DecodeError
+		if assert.True(t, errors.As(err, &decodeErr)) {
+			assert.Equal(t, tt.offset, decodeErr.Offset)
+			assert.Equal(t, tt.paramId, decodeErr.ParamId)
+		}
+		assert.Equal(t, []Record{Temperature{18.5}}, message.Records)
+	}
+}
+
+func TestUnhandledIEEELength(t *testing.T) {
+	// a 2 byte IEEE754 value of an unregistered parameter is left unhandled
+	data, _ := hex.DecodeString("0403000000097f5ef21280003577")
+	message, err := decodePacket(data)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []Record{UnhandledRecord{0x5e, 0xf2, []byte{0x12, 0x80}}}, message.Records)
+	}
+}
+
+func TestLenientCRCFailure(t *testing.T) {
+	data, _ := hex.DecodeString("0403000000097f74921280760501005bb9")
+	message, err := decodePacketLenient(data, true)
+	assert.Nil(t, message)
+	assert.Equal(t, ErrCRCFail, err)
+}
+
+func Example_decodeError() {
+	data, _ := hex.DecodeString("0403000000097f74921280760501005bb8")
+	_, err := decodePacket(data)
+	fmt.Println(err)
+	// Output:
+	// Short or corrupt packet at offset 11, parameter 76: value length 5 overruns packet
+}
+
+func Example_encodeMessageJoin() {
 	message := Message{
 		ManuId: 0x04, ProdId: 0x03, SensorId: 0x00098b,
 		Records: []Record{Join{}},
@@ -172,3 +254,20 @@ func ExampleEncodeMessageJoin() {
 	// Output:
 	// 0403000000098bea00000cab
 }
+
+func Example_encodeMessageCRC() {
+	// The CRC covers the whole encrypted part, from the sensor id. The first
+	// byte of the sensor id was previously left out, giving a CRC of 5917
+	// which receivers reject for sensor ids above 00ffff.
+	message := Message{
+		ManuId: 0x04, ProdId: 0x03, SensorId: 0x12097f,
+		Records: []Record{Join{}},
+	}
+	data := encodeMessage(&message)
+	fmt.Println(hex.EncodeToString(data))
+	_, err := decodePacket(data)
+	fmt.Println(err)
+	// Output:
+	// 0403000012097fea0000c8d3
+	// <nil>
+}
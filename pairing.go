@@ -0,0 +1,191 @@
+package ener314
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// JoinRequest is a join request received from a sensor. Joins are accepted,
+// and acknowledged, while pairing is open or if the sensor is allowed;
+// others are passed to OnJoinRequest for approval with ApproveJoin.
+type JoinRequest struct {
+	SensorId uint32
+	ManuId   byte
+	ProdId   byte
+	Time     time.Time
+	Accepted bool
+}
+
+// PairedDevice is a sensor whose join request has been accepted.
+type PairedDevice struct {
+	SensorId uint32
+	ManuId   byte
+	ProdId   byte
+	Paired   time.Time
+}
+
+// pairing tracks the pairing window, the allowlist and paired sensors.
+type pairing struct {
+	mu      sync.Mutex
+	until   time.Time
+	allowed map[uint32]bool
+	paired  map[uint32]PairedDevice
+}
+
+func (p *pairing) open(until time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.until = until
+}
+
+func (p *pairing) isOpen(now time.Time) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return now.Before(p.until)
+}
+
+func (p *pairing) allow(sensorId uint32, allowed bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if allowed {
+		p.add(sensorId, nil)
+	} else {
+		delete(p.allowed, sensorId)
+		delete(p.paired, sensorId)
+	}
+}
+
+func (p *pairing) isAllowed(sensorId uint32) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.allowed[sensorId]
+}
+
+// join decides a join request, remembering the sensor as paired if accepted.
+func (p *pairing) join(msg *Message, now time.Time) JoinRequest {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	req := JoinRequest{msg.SensorId, msg.ManuId, msg.ProdId, now, false}
+	if now.Before(p.until) || p.allowed[msg.SensorId] {
+		req.Accepted = true
+		if _, ok := p.paired[msg.SensorId]; !ok {
+			p.add(msg.SensorId, &PairedDevice{msg.SensorId, msg.ManuId, msg.ProdId, now})
+		}
+	}
+	return req
+}
+
+// remember allows a previously paired sensor.
+func (p *pairing) remember(pd PairedDevice) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.add(pd.SensorId, &pd)
+}
+
+// reset replaces the allowlist and paired sensors with those given.
+func (p *pairing) reset(devices []PairedDevice) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.allowed = nil
+	p.paired = nil
+	for i := range devices {
+		p.add(devices[i].SensorId, &devices[i])
+	}
+}
+
+// add allows a sensor, and records it as paired if pd is set. The lock must
+// be held.
+func (p *pairing) add(sensorId uint32, pd *PairedDevice) {
+	if p.allowed == nil {
+		p.allowed = map[uint32]bool{}
+		p.paired = map[uint32]PairedDevice{}
+	}
+	p.allowed[sensorId] = true
+	if pd != nil {
+		p.paired[sensorId] = *pd
+	}
+}
+
+func (p *pairing) list() []PairedDevice {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	ret := make([]PairedDevice, 0, len(p.paired))
+	for _, pd := range p.paired {
+		ret = append(ret, pd)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].SensorId < ret[j].SensorId })
+	return ret
+}
+
+// OpenPairing accepts join requests from any sensor for the given duration.
+func (d *Device) OpenPairing(duration time.Duration) {
+	d.log(LOG_INFO, "Pairing open", "duration", duration)
+	d.pairing.open(time.Now().Add(duration))
+}
+
+// ClosePairing stops accepting join requests from sensors not allowed.
+func (d *Device) ClosePairing() {
+	d.pairing.open(time.Time{})
+}
+
+// PairingOpen reports whether join requests from any sensor are accepted.
+func (d *Device) PairingOpen() bool {
+	return d.pairing.isOpen(time.Now())
+}
+
+// ApproveJoin allows a sensor to join, accepting its next join request.
+func (d *Device) ApproveJoin(sensorId uint32) {
+	d.pairing.allow(sensorId, true)
+}
+
+// Unpair removes a sensor from the allowlist and the paired devices.
+func (d *Device) Unpair(sensorId uint32) {
+	d.pairing.allow(sensorId, false)
+	if info, ok := d.sensors.get(sensorId); ok && !info.Paired.IsZero() {
+		info.Paired = time.Time{}
+		d.sensors.put(info)
+		d.saveRegistryLogged()
+	}
+}
+
+// JoinAllowed reports whether join requests from a sensor are accepted
+// outside the pairing window.
+func (d *Device) JoinAllowed(sensorId uint32) bool {
+	return d.pairing.isAllowed(sensorId)
+}
+
+// PairedDevices returns the sensors whose join requests have been accepted.
+func (d *Device) PairedDevices() []PairedDevice {
+	return d.pairing.list()
+}
+
+// handleJoin acknowledges a join request if accepted, and passes it to
+// OnJoinRequest.
+func (d *Device) handleJoin(msg *Message) {
+	req := d.pairing.join(msg, time.Now())
+	if req.Accepted {
+		d.log(LOG_INFO, "Accepted join", "sensor", sensorField(msg.SensorId))
+		d.sensors.modify(msg, func(s *SensorInfo) {
+			if s.Paired.IsZero() {
+				s.Paired = req.Time
+			}
+		})
+		d.saveRegistryLogged()
+		err := d.Send(&Message{
+			ManuId:   msg.ManuId,
+			ProdId:   msg.ProdId,
+			SensorId: msg.SensorId,
+			Records:  []Record{JoinReport{}},
+		})
+		if err != nil {
+			d.log(LOG_ERROR, "Join acknowledgement failed", "dir", "tx", "sensor", sensorField(msg.SensorId), "error", err)
+		}
+	} else {
+		d.log(LOG_INFO, "Join awaiting approval", "sensor", sensorField(msg.SensorId))
+	}
+	d.publish(req)
+	if d.OnJoinRequest != nil {
+		d.OnJoinRequest(req)
+	}
+}
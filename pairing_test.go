@@ -0,0 +1,39 @@
+package ener314
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPairing(t *testing.T) {
+	p := &pairing{}
+	now := time.Now()
+	join := func(sensorId uint32, at time.Time) bool {
+		msg := &Message{ManuId: energenieManuId, ProdId: eTRVProdId, SensorId: sensorId, Records: []Record{Join{}}}
+		return p.join(msg, at).Accepted
+	}
+
+	assert.False(t, join(0x000001, now))
+
+	p.open(now.Add(time.Minute))
+	assert.True(t, p.isOpen(now))
+	assert.True(t, join(0x000001, now))
+	assert.False(t, join(0x000002, now.Add(2*time.Minute)))
+
+	// paired sensors may rejoin after pairing has closed
+	assert.True(t, join(0x000001, now.Add(2*time.Minute)))
+
+	p.allow(0x000002, true)
+	assert.True(t, join(0x000002, now.Add(3*time.Minute)))
+
+	assert.Equal(t, []PairedDevice{
+		{0x000001, energenieManuId, eTRVProdId, now},
+		{0x000002, energenieManuId, eTRVProdId, now.Add(3 * time.Minute)},
+	}, p.list())
+
+	p.allow(0x000001, false)
+	assert.False(t, p.isAllowed(0x000001))
+	assert.Len(t, p.list(), 1)
+}
@@ -0,0 +1,124 @@
+package ener314
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Direction a parameter travels in: reported by a device to the gateway, or
+// sent as a command from the gateway to a device.
+type Direction int
+
+const (
+	DIRECTION_REPORT  Direction = 1
+	DIRECTION_COMMAND Direction = 2
+	DIRECTION_BOTH    Direction = DIRECTION_REPORT | DIRECTION_COMMAND
+)
+
+func (d Direction) String() string {
+	switch d {
+	case DIRECTION_REPORT:
+		return "report"
+	case DIRECTION_COMMAND:
+		return "command"
+	case DIRECTION_BOTH:
+		return "both"
+	}
+	return fmt.Sprintf("Direction(%d)", int(d))
+}
+
+// Parameter describes an OpenThings parameter.
+type Parameter struct {
+	ID        byte
+	Name      string
+	Unit      string
+	Direction Direction
+	Encoding  byte // encoding normally used on the wire
+}
+
+// Parameters is the OpenThings parameter list.
+var Parameters = []Parameter{
+	{OT_ALARM, "ALARM", "", DIRECTION_REPORT, ENC_UINT},
+	{OT_REPORT_DIAGNOSTICS, "DIAGNOSTICS", "", DIRECTION_REPORT, ENC_UINT},
+	{OT_DEBUG_OUTPUT, "DEBUG_OUTPUT", "", DIRECTION_REPORT, ENC_CHARS},
+	{OT_WATER_DETECTOR, "WATER_DETECTOR", "", DIRECTION_REPORT, ENC_UINT},
+	{OT_REPORT_VOLTAGE, "BATTERY_VOLTAGE", "V", DIRECTION_REPORT, ENC_UFPp8},
+	{OT_DOOR_SENSOR, "DOOR_SENSOR", "", DIRECTION_REPORT, ENC_UINT},
+	{OT_FREQUENCY, "FREQUENCY", "Hz", DIRECTION_REPORT, ENC_UFPp8},
+	{OT_HUMIDITY, "HUMIDITY", "%", DIRECTION_REPORT, ENC_UFPp8},
+	{OT_CURRENT, "CURRENT", "A", DIRECTION_REPORT, ENC_UFPp8},
+	{OT_JOIN_RESP, "JOIN_ACK", "", DIRECTION_COMMAND, ENC_UINT},
+	{OT_LIGHT_LEVEL, "LIGHT_LEVEL", "lx", DIRECTION_REPORT, ENC_UINT},
+	{OT_MOTION_DETECTOR, "MOTION_DETECTOR", "", DIRECTION_REPORT, ENC_UINT},
+	{OT_POWER_FACTOR, "POWER_FACTOR", "", DIRECTION_REPORT, ENC_SFPp8},
+	{OT_OCCUPANCY, "OCCUPANCY", "", DIRECTION_REPORT, ENC_UINT},
+	{OT_POWER, "REAL_POWER", "W", DIRECTION_REPORT, ENC_SINT},
+	{OT_REACTIVE_P, "REACTIVE_POWER", "VAR", DIRECTION_REPORT, ENC_SINT},
+	{OT_ROTATION_SPEED, "ROTATION_SPEED", "rpm", DIRECTION_REPORT, ENC_UINT},
+	{OT_SW_STATE, "SWITCH_STATE", "", DIRECTION_REPORT, ENC_UINT},
+	{OT_TEMP_REPORT, "TEMPERATURE", "°C", DIRECTION_REPORT, ENC_SFPp8},
+	{OT_VOLTAGE, "VOLTAGE", "V", DIRECTION_REPORT, ENC_UFPp8},
+	{OT_WATER_FLOW_RATE, "WATER_FLOW_RATE", "l/h", DIRECTION_REPORT, ENC_UFPp8},
+	{OT_WATER_PRESSURE, "WATER_PRESSURE", "Pa", DIRECTION_REPORT, ENC_UINT},
+	{OT_PHASE1_POWER, "PHASE_1_POWER", "W", DIRECTION_REPORT, ENC_SINT},
+	{OT_PHASE2_POWER, "PHASE_2_POWER", "W", DIRECTION_REPORT, ENC_SINT},
+	{OT_PHASE3_POWER, "PHASE_3_POWER", "W", DIRECTION_REPORT, ENC_SINT},
+	{OT_3PHASE_POWER, "3_PHASE_TOTAL_POWER", "W", DIRECTION_REPORT, ENC_SINT},
+	{OT_EXERCISE_VALVE, "EXERCISE_VALVE", "", DIRECTION_COMMAND, ENC_UINT},
+	{OT_SET_LOW_POWER_MODE, "SET_LOW_POWER_MODE", "", DIRECTION_COMMAND, ENC_UINT},
+	{OT_SET_VALVE_STATE, "SET_VALVE_STATE", "", DIRECTION_COMMAND, ENC_UINT},
+	{OT_REQUEST_DIAGNOSTICS, "REQUEST_DIAGNOSTICS", "", DIRECTION_COMMAND, ENC_UINT},
+	{OT_TEST, "TEST", "", DIRECTION_BOTH, ENC_UINT},
+	{OT_IDENTIFY, "IDENTIFY", "", DIRECTION_COMMAND, ENC_UINT},
+	{OT_SOURCE_SELECTOR, "SOURCE_SELECTOR", "", DIRECTION_COMMAND, ENC_UINT},
+	{OT_SET_REPORTING_INTERVAL, "SET_REPORTING_INTERVAL", "s", DIRECTION_COMMAND, ENC_UINT},
+	{OT_GLOBAL_READ, "GLOBAL_READ", "", DIRECTION_COMMAND, ENC_UINT},
+	{OT_REQUEST_VOLTAGE, "REQUEST_VOLTAGE", "", DIRECTION_COMMAND, ENC_UINT},
+	{OT_JOIN_CMD, "JOIN", "", DIRECTION_REPORT, ENC_UINT},
+	{OT_ACTUATE_SW, "SWITCH", "", DIRECTION_COMMAND, ENC_UINT},
+	{OT_TEMP_SET, "TARGET_TEMPERATURE", "°C", DIRECTION_COMMAND, ENC_SFPp8},
+}
+
+var (
+	parameterIndex = map[byte]*Parameter{}
+	parameterNames = map[string]*Parameter{}
+)
+
+func init() {
+	for i := range Parameters {
+		parameterIndex[Parameters[i].ID] = &Parameters[i]
+		parameterNames[Parameters[i].Name] = &Parameters[i]
+	}
+}
+
+// LookupParameter returns the description of the parameter with the given id.
+func LookupParameter(id byte) (Parameter, bool) {
+	if p, ok := parameterIndex[id]; ok {
+		return *p, true
+	}
+	return Parameter{}, false
+}
+
+// LookupParameterName returns the description of the parameter with the given
+// name. Unknown parameters may be named UNKNOWN_XX, with the id in hex.
+func LookupParameterName(name string) (Parameter, bool) {
+	if p, ok := parameterNames[name]; ok {
+		return *p, true
+	}
+	if strings.HasPrefix(name, "UNKNOWN_") {
+		if id, err := strconv.ParseUint(name[len("UNKNOWN_"):], 16, 8); err == nil {
+			return parameter(byte(id)), true
+		}
+	}
+	return Parameter{}, false
+}
+
+// parameter returns the description of the parameter with the given id, or a
+// placeholder for unknown parameters.
+func parameter(id byte) Parameter {
+	if p, ok := LookupParameter(id); ok {
+		return p
+	}
+	return Parameter{ID: id, Name: fmt.Sprintf("UNKNOWN_%02X", id)}
+}
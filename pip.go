@@ -0,0 +1,50 @@
+package ener314
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"sync"
+)
+
+// A PipSource generates the PIP, the nonce encrypting each transmitted
+// message.
+type PipSource interface {
+	Pip() uint16
+}
+
+type randomPipSource struct{}
+
+func (randomPipSource) Pip() uint16 {
+	var b [2]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	return binary.BigEndian.Uint16(b[:])
+}
+
+// DefaultPipSource draws PIPs from crypto/rand.
+var DefaultPipSource PipSource = randomPipSource{}
+
+// SequencePipSource returns PIPs from a fixed sequence, repeating the last
+// once exhausted. Useful for reproducible frames in tests.
+type SequencePipSource struct {
+	mu   sync.Mutex
+	pips []uint16
+}
+
+func NewSequencePipSource(pips ...uint16) *SequencePipSource {
+	return &SequencePipSource{pips: pips}
+}
+
+func (s *SequencePipSource) Pip() uint16 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.pips) == 0 {
+		return 0
+	}
+	pip := s.pips[0]
+	if len(s.pips) > 1 {
+		s.pips = s.pips[1:]
+	}
+	return pip
+}
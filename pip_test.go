@@ -0,0 +1,45 @@
+package ener314
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// encrypted frames for sensor 00097f with pip 1234
+var goldenCommandsTable = []struct {
+	record  Record
+	encoded string
+}{
+	{Identify{}, "04031234dc1c421479632c8c"},
+	{JoinReport{}, "04031234dc1c42c17963a2e8"},
+	{Voltage{}, "04031234dc1c424979633013"},
+	{Diagnostics{}, "04031234dc1c420d7963f17e"},
+	{ExerciseValve{}, "04031234dc1c420879631a8e"},
+	{Temperature{21}, "04031234dc1c425feb76c0a52b5f"},
+	{ReportInterval{300}, "04031234dc1c42797b62eca5f4d9"},
+	{SetValveState{VALVE_STATE_OPEN}, "04031234dc1c420e7863c0f42b"},
+	{SetPowerMode{POWER_MODE_LOW}, "04031234dc1c420f7862c0b1ae"},
+	{ActuateSwitch{true}, "04031234dc1c42587862c095b8"},
+}
+
+func TestGoldenCommands(t *testing.T) {
+	for _, tt := range goldenCommandsTable {
+		message := &Message{ManuId: energenieManuId, ProdId: eTRVProdId, SensorId: 0x00097f, Records: []Record{tt.record}}
+		data := Encode(message, NewSequencePipSource(0x1234))
+		assert.Equal(t, tt.encoded, hex.EncodeToString(data), "%s", tt.record)
+
+		decoded, err := Decode(data, DecodeOptions{})
+		assert.NoError(t, err)
+		assert.Equal(t, uint16(0x1234), decoded.Pip)
+	}
+}
+
+func TestSequencePipSource(t *testing.T) {
+	pips := NewSequencePipSource(1, 2)
+	assert.Equal(t, uint16(1), pips.Pip())
+	assert.Equal(t, uint16(2), pips.Pip())
+	assert.Equal(t, uint16(2), pips.Pip())
+	assert.Equal(t, uint16(0), NewSequencePipSource().Pip())
+}
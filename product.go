@@ -0,0 +1,274 @@
+package ener314
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+)
+
+// Command is a parameter a product accepts, and the range of values it
+// accepts for it.
+type Command struct {
+	Param    byte
+	Min, Max float64
+}
+
+// InRange reports whether value is within the accepted range.
+func (c Command) InRange(value float64) bool {
+	return value >= c.Min && value <= c.Max
+}
+
+// Product describes a manufacturer/product: its name, encryption id, the
+// parameters it reports and the commands it accepts.
+type Product struct {
+	ManuId       byte
+	ProdId       byte
+	Name         string
+	EncryptionId byte // zero for DefaultEncryptionId
+	Reports      []byte
+	Commands     []Command
+}
+
+// Command returns the range of values accepted for a command parameter.
+func (p Product) Command(paramId byte) (Command, bool) {
+	for _, c := range p.Commands {
+		if c.Param == paramId {
+			return c, true
+		}
+	}
+	return Command{}, false
+}
+
+// RangeError is a command value outside the range a product accepts.
+type RangeError struct {
+	ParamId  byte
+	Value    float64
+	Min, Max float64
+}
+
+func (e *RangeError) Error() string {
+	return fmt.Sprintf("%s out of range: %v < %v < %v", parameter(e.ParamId).Name, e.Min, e.Value, e.Max)
+}
+
+// UnsupportedCommandError is a command a product does not accept.
+type UnsupportedCommandError struct {
+	Product string
+	ParamId byte
+}
+
+func (e *UnsupportedCommandError) Error() string {
+	return fmt.Sprintf("%s does not accept %s", e.Product, parameter(e.ParamId).Name)
+}
+
+// checkCommand checks the product accepts a command with the given value.
+func (p Product) checkCommand(paramId byte, value float64) error {
+	c, ok := p.Command(paramId)
+	if !ok {
+		return &UnsupportedCommandError{p.Name, paramId}
+	}
+	if !c.InRange(value) {
+		return &RangeError{paramId, value, c.Min, c.Max}
+	}
+	return nil
+}
+
+// Reported reports whether the product reports a parameter.
+func (p Product) Reported(paramId byte) bool {
+	for _, id := range p.Reports {
+		if id == paramId {
+			return true
+		}
+	}
+	return false
+}
+
+// builtinProducts is the catalogue of supported Energenie products.
+var builtinProducts = []Product{
+	{energenieManuId, monitorProdId, "MIHO004 Monitor", 0,
+		[]byte{OT_JOIN_CMD, OT_POWER, OT_REACTIVE_P, OT_VOLTAGE, OT_FREQUENCY},
+		[]Command{{OT_JOIN_RESP, 0, 0}}},
+	{energenieManuId, adapterProdId, "MIHO005 Adapter Plus", 0,
+		[]byte{OT_JOIN_CMD, OT_POWER, OT_REACTIVE_P, OT_VOLTAGE, OT_FREQUENCY, OT_SW_STATE},
+		[]Command{{OT_JOIN_RESP, 0, 0}, {OT_ACTUATE_SW, 0, 1}}},
+	{energenieManuId, eTRVProdId, "MIHO013 eTRV", 0xf2,
+		[]byte{OT_JOIN_CMD, OT_TEMP_REPORT, OT_VOLTAGE, OT_REPORT_DIAGNOSTICS, OT_TEMP_SET},
+		[]Command{
+			{OT_JOIN_RESP, 0, 0},
+			{OT_IDENTIFY, 0, 0},
+			{OT_TEMP_SET, 0, 30},
+			{OT_SET_REPORTING_INTERVAL, 1, 3600},
+			{OT_REQUEST_VOLTAGE, 0, 0},
+			{OT_REQUEST_DIAGNOSTICS, 0, 0},
+			{OT_EXERCISE_VALVE, 0, 0},
+			{OT_SET_VALVE_STATE, 0, 2},
+			{OT_SET_LOW_POWER_MODE, 0, 1},
+		}},
+	{energenieManuId, energyProdId, "MIHO006 House monitor", 0,
+		[]byte{OT_JOIN_CMD, OT_CURRENT, OT_POWER, OT_VOLTAGE},
+		[]Command{{OT_JOIN_RESP, 0, 0}}},
+	{energenieManuId, motionProdId, "MIHO032 Motion sensor", 0,
+		[]byte{OT_JOIN_CMD, OT_MOTION_DETECTOR, OT_REPORT_VOLTAGE},
+		[]Command{{OT_JOIN_RESP, 0, 0}}},
+	{energenieManuId, openProdId, "MIHO033 Open sensor", 0,
+		[]byte{OT_JOIN_CMD, OT_DOOR_SENSOR, OT_REPORT_VOLTAGE},
+		[]Command{{OT_JOIN_RESP, 0, 0}}},
+}
+
+var (
+	productsLock sync.RWMutex
+	products     = map[productKey]Product{}
+)
+
+func init() {
+	for _, p := range builtinProducts {
+		RegisterProduct(p)
+	}
+}
+
+// RegisterProduct adds a product to the catalogue, replacing any previous
+// description of the manufacturer/product, and registers its encryption id.
+func RegisterProduct(p Product) {
+	productsLock.Lock()
+	products[productKey{p.ManuId, p.ProdId}] = p
+	productsLock.Unlock()
+	if p.EncryptionId != 0 {
+		SetEncryptionId(p.ManuId, p.ProdId, p.EncryptionId)
+	}
+}
+
+// UnregisterProduct removes a product from the catalogue, along with the
+// encryption id it registered.
+func UnregisterProduct(manuId, prodId byte) {
+	key := productKey{manuId, prodId}
+	productsLock.Lock()
+	p, ok := products[key]
+	delete(products, key)
+	productsLock.Unlock()
+	if ok && p.EncryptionId != 0 {
+		encryptionLock.Lock()
+		delete(encryptionIds, key)
+		encryptionLock.Unlock()
+	}
+}
+
+// LookupProduct returns the catalogue entry for a manufacturer/product.
+func LookupProduct(manuId, prodId byte) (Product, bool) {
+	productsLock.RLock()
+	defer productsLock.RUnlock()
+	p, ok := products[productKey{manuId, prodId}]
+	return p, ok
+}
+
+// Products returns the catalogue, ordered by manufacturer and product.
+func Products() []Product {
+	productsLock.RLock()
+	ret := make([]Product, 0, len(products))
+	for _, p := range products {
+		ret = append(ret, p)
+	}
+	productsLock.RUnlock()
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].ManuId != ret[j].ManuId {
+			return ret[i].ManuId < ret[j].ManuId
+		}
+		return ret[i].ProdId < ret[j].ProdId
+	})
+	return ret
+}
+
+// LoadProducts registers each product in a JSON array of product
+// descriptors, see Product.MarshalJSON.
+func LoadProducts(r io.Reader) error {
+	var ps []Product
+	if err := json.NewDecoder(r).Decode(&ps); err != nil {
+		return err
+	}
+	for _, p := range ps {
+		RegisterProduct(p)
+	}
+	return nil
+}
+
+// LoadProductsFile registers the products described in a JSON file.
+func LoadProductsFile(filename string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return LoadProducts(f)
+}
+
+// Product returns the catalogue entry for the message's product.
+func (m *Message) Product() (Product, bool) {
+	return LookupProduct(m.ManuId, m.ProdId)
+}
+
+type jsonCommand struct {
+	Param string  `json:"param"`
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+}
+
+type jsonProduct struct {
+	ManuId       byte          `json:"manu_id"`
+	ProdId       byte          `json:"prod_id"`
+	Name         string        `json:"name"`
+	EncryptionId byte          `json:"encryption_id,omitempty"`
+	Reports      []string      `json:"reports"`
+	Commands     []jsonCommand `json:"commands"`
+}
+
+// MarshalJSON describes the product with parameters by name, for example:
+//
+//	{"manu_id":4,"prod_id":1,"name":"MIHO004 Monitor",
+//	 "reports":["JOIN","REAL_POWER"],
+//	 "commands":[{"param":"JOIN_ACK","min":0,"max":0}]}
+func (p Product) MarshalJSON() ([]byte, error) {
+	jp := jsonProduct{
+		ManuId:       p.ManuId,
+		ProdId:       p.ProdId,
+		Name:         p.Name,
+		EncryptionId: p.EncryptionId,
+		Reports:      []string{},
+		Commands:     []jsonCommand{},
+	}
+	for _, id := range p.Reports {
+		jp.Reports = append(jp.Reports, parameter(id).Name)
+	}
+	for _, c := range p.Commands {
+		jp.Commands = append(jp.Commands, jsonCommand{parameter(c.Param).Name, c.Min, c.Max})
+	}
+	return json.Marshal(jp)
+}
+
+func (p *Product) UnmarshalJSON(data []byte) error {
+	var jp jsonProduct
+	if err := json.Unmarshal(data, &jp); err != nil {
+		return err
+	}
+	*p = Product{
+		ManuId:       jp.ManuId,
+		ProdId:       jp.ProdId,
+		Name:         jp.Name,
+		EncryptionId: jp.EncryptionId,
+	}
+	for _, name := range jp.Reports {
+		param, ok := LookupParameterName(name)
+		if !ok {
+			return fmt.Errorf("Unknown parameter %q", name)
+		}
+		p.Reports = append(p.Reports, param.ID)
+	}
+	for _, c := range jp.Commands {
+		param, ok := LookupParameterName(c.Param)
+		if !ok {
+			return fmt.Errorf("Unknown parameter %q", c.Param)
+		}
+		p.Commands = append(p.Commands, Command{param.ID, c.Min, c.Max})
+	}
+	return nil
+}
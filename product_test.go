@@ -0,0 +1,68 @@
+package ener314
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLookupProduct(t *testing.T) {
+	p, ok := LookupProduct(energenieManuId, eTRVProdId)
+	assert.True(t, ok)
+	assert.Equal(t, "MIHO013 eTRV", p.Name)
+	assert.True(t, p.Reported(OT_TEMP_REPORT))
+
+	c, ok := p.Command(OT_TEMP_SET)
+	assert.True(t, ok)
+	assert.True(t, c.InRange(21))
+	assert.False(t, c.InRange(31))
+
+	_, ok = p.Command(OT_ACTUATE_SW)
+	assert.False(t, ok)
+
+	_, ok = LookupProduct(0x7c, 0x01)
+	assert.False(t, ok)
+}
+
+func TestLoadProducts(t *testing.T) {
+	descriptors := `[{
+		"manu_id": 124, "prod_id": 1, "name": "Test sensor", "encryption_id": 66,
+		"reports": ["TEMPERATURE", "HUMIDITY", "UNKNOWN_5F"],
+		"commands": [{"param": "SET_REPORTING_INTERVAL", "min": 10, "max": 600}]
+	}]`
+	assert.NoError(t, LoadProducts(strings.NewReader(descriptors)))
+	t.Cleanup(func() { UnregisterProduct(0x7c, 0x01) })
+
+	p, ok := LookupProduct(0x7c, 0x01)
+	assert.True(t, ok)
+	assert.Equal(t, Product{
+		ManuId: 0x7c, ProdId: 0x01, Name: "Test sensor", EncryptionId: 0x42,
+		Reports:  []byte{OT_TEMP_REPORT, OT_HUMIDITY, 0x5f},
+		Commands: []Command{{OT_SET_REPORTING_INTERVAL, 10, 600}},
+	}, p)
+	assert.Equal(t, byte(0x42), EncryptionId(0x7c, 0x01))
+	assert.Contains(t, Products(), p)
+
+	b, err := json.Marshal(p)
+	assert.NoError(t, err)
+	var decoded Product
+	assert.NoError(t, json.Unmarshal(b, &decoded))
+	assert.Equal(t, p, decoded)
+
+	err = LoadProducts(strings.NewReader(`[{"manu_id": 124, "prod_id": 2, "reports": ["BOGUS"]}]`))
+	assert.EqualError(t, err, `Unknown parameter "BOGUS"`)
+}
+
+func TestUnregisterProduct(t *testing.T) {
+	RegisterProduct(Product{ManuId: 0x7c, ProdId: 0x03, Name: "Test valve", EncryptionId: 0x43})
+	_, ok := LookupProduct(0x7c, 0x03)
+	assert.True(t, ok)
+	assert.Equal(t, byte(0x43), EncryptionId(0x7c, 0x03))
+
+	UnregisterProduct(0x7c, 0x03)
+	_, ok = LookupProduct(0x7c, 0x03)
+	assert.False(t, ok)
+	assert.Equal(t, byte(DefaultEncryptionId), EncryptionId(0x7c, 0x03))
+}
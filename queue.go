@@ -0,0 +1,391 @@
+package ener314
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultCommandExpiry is how long a queued command waits for its sensor
+	// to report before it is dropped.
+	DefaultCommandExpiry = time.Hour
+	// DefaultCommandAttempts is how many times a queued command is sent
+	// before it is failed as unconfirmed.
+	DefaultCommandAttempts = 3
+)
+
+var (
+	ErrNotConfirmed     = errors.New("Command not confirmed")
+	ErrCommandExpired   = errors.New("Command expired")
+	ErrCommandReplaced  = errors.New("Command replaced")
+	ErrCommandCancelled = errors.New("Command cancelled")
+)
+
+// QueuedCommand is a command waiting to be sent to a sensor in the receive
+// window following its next report, or waiting for confirmation.
+type QueuedCommand struct {
+	ID       uint64
+	SensorId uint32
+	Record   Record
+	Queued   time.Time
+	Expires  time.Time // zero if the command does not expire
+	Attempts int       // times sent so far
+	Sent     time.Time // when last sent
+}
+
+// CommandResult is the outcome of a queued command: a nil Err once sent, or
+// for commands that expect a confirming report, once confirmed.
+type CommandResult struct {
+	Command QueuedCommand
+	Err     error
+}
+
+// A confirmable command is acknowledged by a report from the sensor, such as
+// a Voltage report after a voltage request.
+type confirmable interface {
+	confirmedBy(report Record) bool
+}
+
+func (v Voltage) confirmedBy(report Record) bool {
+	switch report.(type) {
+	case Voltage, BatteryVoltage:
+		return true
+	}
+	return false
+}
+
+func (v Diagnostics) confirmedBy(report Record) bool {
+	_, ok := report.(Diagnostics)
+	return ok
+}
+
+// exerciseResult are the diagnostic flags reporting the result of exercising
+// the valve.
+const exerciseResult = 0x0600
+
+func (v ExerciseValve) confirmedBy(report Record) bool {
+	d, ok := report.(Diagnostics)
+	return ok && d.Value&exerciseResult != 0
+}
+
+func (t Temperature) confirmedBy(report Record) bool {
+	return SetTemperature(t).confirmedBy(report)
+}
+
+func (t SetTemperature) confirmedBy(report Record) bool {
+	s, ok := report.(SetTemperature)
+	return ok && math.Abs(s.Value-t.Value) < 1.0/256
+}
+
+func (v ActuateSwitch) confirmedBy(report Record) bool {
+	s, ok := report.(SwitchState)
+	return ok && s.On == v.On
+}
+
+// commandParam is the parameter id a command is sent as.
+func commandParam(record Record) byte {
+	param, _ := commandValue(record)
+	return param
+}
+
+// commandValue is the parameter id and value a command is sent as.
+func commandValue(record Record) (byte, float64) {
+	var buf bytes.Buffer
+	record.Encode(&buf)
+	b := buf.Bytes()
+	if len(b) < 2 {
+		return 0, 0
+	}
+	end := 2 + int(b[1]&0x0f)
+	if end > len(b) {
+		end = len(b)
+	}
+	return b[0], DecodeValue(b[1], b[2:end]).Float64()
+}
+
+// commandQueue holds the commands queued for each sensor.
+type commandQueue struct {
+	mu      sync.Mutex
+	lastId  uint64
+	sensors map[uint32][]*QueuedCommand
+	notify  map[uint64]chan<- CommandResult
+}
+
+// add queues a command, replacing any queued command for the same parameter.
+func (q *commandQueue) add(sensorId uint32, record Record, expiry time.Duration, results chan<- CommandResult, now time.Time) (QueuedCommand, []CommandResult) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.lastId += 1
+	cmd := &QueuedCommand{ID: q.lastId, SensorId: sensorId, Record: record, Queued: now}
+	if expiry > 0 {
+		cmd.Expires = now.Add(expiry)
+	}
+	if results != nil {
+		if q.notify == nil {
+			q.notify = map[uint64]chan<- CommandResult{}
+		}
+		q.notify[cmd.ID] = results
+	}
+	cmds := q.sensors[sensorId]
+	param := commandParam(record)
+	for i, c := range cmds {
+		if commandParam(c.Record) == param {
+			cmds[i] = cmd
+			return *cmd, []CommandResult{{*c, ErrCommandReplaced}}
+		}
+	}
+	if q.sensors == nil {
+		q.sensors = map[uint32][]*QueuedCommand{}
+	}
+	q.sensors[sensorId] = append(cmds, cmd)
+	return *cmd, nil
+}
+
+// filter keeps the sensor's commands for which keep returns a nil error,
+// returning the results of those removed. The lock must be held.
+func (q *commandQueue) filter(sensorId uint32, keep func(c *QueuedCommand) error) []CommandResult {
+	var results []CommandResult
+	cmds := q.sensors[sensorId]
+	live := cmds[:0]
+	for _, c := range cmds {
+		if err := keep(c); err != nil {
+			results = append(results, CommandResult{*c, err})
+			continue
+		}
+		live = append(live, c)
+	}
+	if len(live) == 0 {
+		delete(q.sensors, sensorId)
+	} else {
+		q.sensors[sensorId] = live
+	}
+	return results
+}
+
+// expire drops the sensor's expired commands. The lock must be held.
+func (q *commandQueue) expire(sensorId uint32, now time.Time) []CommandResult {
+	return q.filter(sensorId, func(c *QueuedCommand) error {
+		if !c.Expires.IsZero() && now.After(c.Expires) {
+			return ErrCommandExpired
+		}
+		return nil
+	})
+}
+
+// list returns the commands queued for a sensor.
+func (q *commandQueue) list(sensorId uint32, now time.Time) ([]QueuedCommand, []CommandResult) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	results := q.expire(sensorId, now)
+	var ret []QueuedCommand
+	for _, c := range q.sensors[sensorId] {
+		ret = append(ret, *c)
+	}
+	return ret, results
+}
+
+// cancel removes a queued command by id.
+func (q *commandQueue) cancel(id uint64) []CommandResult {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for sensorId, cmds := range q.sensors {
+		for _, c := range cmds {
+			if c.ID == id {
+				return q.filter(sensorId, func(c *QueuedCommand) error {
+					if c.ID == id {
+						return ErrCommandCancelled
+					}
+					return nil
+				})
+			}
+		}
+	}
+	return nil
+}
+
+// cancelAll removes all of a sensor's queued commands.
+func (q *commandQueue) cancelAll(sensorId uint32) []CommandResult {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.filter(sensorId, func(*QueuedCommand) error { return ErrCommandCancelled })
+}
+
+// confirm removes the sensor's sent commands confirmed by the records of a
+// report.
+func (q *commandQueue) confirm(msg *Message) []CommandResult {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var confirmed []CommandResult
+	q.filter(msg.SensorId, func(c *QueuedCommand) error {
+		cc, ok := c.Record.(confirmable)
+		if !ok || c.Attempts == 0 {
+			return nil
+		}
+		for _, report := range msg.Records {
+			if cc.confirmedBy(report) {
+				confirmed = append(confirmed, CommandResult{*c, nil})
+				return errConfirmed
+			}
+		}
+		return nil
+	})
+	return confirmed
+}
+
+// errConfirmed marks commands removed by confirm, which reports them with a
+// nil error.
+var errConfirmed = errors.New("confirmed")
+
+// due returns the sensor's commands to send now, failing those expired or
+// already sent maxAttempts times unconfirmed, DefaultCommandAttempts if
+// maxAttempts is not positive. Commands that expect no confirmation are
+// removed, and the caller reports their result once sent.
+func (q *commandQueue) due(sensorId uint32, maxAttempts int, now time.Time) ([]QueuedCommand, []CommandResult) {
+	if maxAttempts <= 0 {
+		maxAttempts = DefaultCommandAttempts
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	results := q.expire(sensorId, now)
+	results = append(results, q.filter(sensorId, func(c *QueuedCommand) error {
+		if _, ok := c.Record.(confirmable); ok && c.Attempts >= maxAttempts {
+			return ErrNotConfirmed
+		}
+		return nil
+	})...)
+	var cmds []QueuedCommand
+	q.filter(sensorId, func(c *QueuedCommand) error {
+		c.Attempts += 1
+		c.Sent = now
+		cmds = append(cmds, *c)
+		if _, ok := c.Record.(confirmable); !ok {
+			return errConfirmed
+		}
+		return nil
+	})
+	return cmds, results
+}
+
+// take returns the channel to notify of a command's result, if any.
+func (q *commandQueue) take(id uint64) chan<- CommandResult {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	ch := q.notify[id]
+	delete(q.notify, id)
+	return ch
+}
+
+// Queue queues a command to send to a sensor in the receive window following
+// its next report, for sensors such as the eTRV that only listen briefly
+// after transmitting. A queued command replaces any queued for the same
+// parameter, so the latest target temperature wins. Commands for sensors in
+// the registry are checked against those their product accepts.
+//
+// Commands expecting a confirming report (such as a Voltage report after a
+// voltage request) are resent following each report until confirmed, up to
+// CommandAttempts times. The result is passed to OnCommandResult.
+func (d *Device) Queue(sensorId uint32, record Record) (QueuedCommand, error) {
+	return d.QueueNotify(sensorId, record, nil)
+}
+
+// QueueNotify is Queue, additionally sending the result on results. The
+// send does not block, so results should be buffered.
+func (d *Device) QueueNotify(sensorId uint32, record Record, results chan<- CommandResult) (QueuedCommand, error) {
+	if err := d.validateCommand(sensorId, record); err != nil {
+		return QueuedCommand{}, err
+	}
+	cmd, replaced := d.queue.add(sensorId, record, d.CommandExpiry, results, time.Now())
+	d.commandResults(replaced)
+	return cmd, nil
+}
+
+// validateCommand checks a command is accepted by the product of a sensor in
+// the registry.
+func (d *Device) validateCommand(sensorId uint32, record Record) error {
+	info, ok := d.sensors.get(sensorId)
+	if !ok {
+		return nil
+	}
+	p, ok := LookupProduct(info.ManuId, info.ProdId)
+	if !ok {
+		return nil
+	}
+	return p.checkCommand(commandValue(record))
+}
+
+// QueuedCommands returns the commands waiting to be sent to a sensor, or
+// waiting for confirmation.
+func (d *Device) QueuedCommands(sensorId uint32) []QueuedCommand {
+	cmds, expired := d.queue.list(sensorId, time.Now())
+	d.commandResults(expired)
+	return cmds
+}
+
+// CancelCommand removes a queued command, returning false if it has already
+// completed.
+func (d *Device) CancelCommand(id uint64) bool {
+	results := d.queue.cancel(id)
+	d.commandResults(results)
+	return len(results) > 0
+}
+
+// CancelCommands removes all commands queued for a sensor, returning how many
+// were removed.
+func (d *Device) CancelCommands(sensorId uint32) int {
+	results := d.queue.cancelAll(sensorId)
+	d.commandResults(results)
+	return len(results)
+}
+
+// sendQueued handles the commands queued for the sensor of a received
+// message: confirming those acknowledged by the message, and sending those
+// due with the message's manufacturer and product.
+func (d *Device) sendQueued(msg *Message) {
+	results := d.queue.confirm(msg)
+	cmds, failed := d.queue.due(msg.SensorId, d.CommandAttempts, time.Now())
+	results = append(results, failed...)
+	if len(cmds) > 0 {
+		message := &Message{
+			ManuId:   msg.ManuId,
+			ProdId:   msg.ProdId,
+			SensorId: msg.SensorId,
+		}
+		for _, c := range cmds {
+			message.Records = append(message.Records, c.Record)
+		}
+		err := d.Send(message)
+		if err != nil {
+			d.log(LOG_ERROR, "Sending queued commands failed", "dir", "tx", "sensor", sensorField(msg.SensorId), "error", err)
+		}
+		for _, c := range cmds {
+			if _, ok := c.Record.(confirmable); !ok {
+				results = append(results, CommandResult{c, err})
+			}
+		}
+	}
+	d.commandResults(results)
+}
+
+func (d *Device) commandResults(results []CommandResult) {
+	for _, r := range results {
+		if r.Err == nil {
+			d.log(LOG_TRACE, "Command completed", "sensor", sensorField(r.Command.SensorId), "command", r.Command.Record, "attempts", r.Command.Attempts)
+		} else {
+			d.log(LOG_WARN, "Command failed", "sensor", sensorField(r.Command.SensorId), "command", r.Command.Record, "attempts", r.Command.Attempts, "error", r.Err)
+		}
+		if ch := d.queue.take(r.Command.ID); ch != nil {
+			select {
+			case ch <- r:
+			default:
+				d.log(LOG_WARN, "Result channel full, dropped result", "sensor", sensorField(r.Command.SensorId), "command", r.Command.Record)
+			}
+		}
+		d.publish(r)
+		if d.OnCommandResult != nil {
+			d.OnCommandResult(r)
+		}
+	}
+}
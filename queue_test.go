@@ -0,0 +1,124 @@
+package ener314
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCommandQueue(t *testing.T) {
+	q := &commandQueue{}
+	now := time.Now()
+
+	target, _ := q.add(0x00097f, Temperature{18}, time.Hour, nil, now)
+	voltage, _ := q.add(0x00097f, Voltage{}, time.Hour, nil, now)
+	q.add(0x000980, Identify{}, 0, nil, now)
+	// latest target temperature wins, keeping its place in the queue
+	latest, replaced := q.add(0x00097f, Temperature{21}, time.Hour, nil, now.Add(time.Minute))
+	assert.NotEqual(t, target.ID, latest.ID)
+	assert.Equal(t, []CommandResult{{target, ErrCommandReplaced}}, replaced)
+
+	cmds, _ := q.list(0x00097f, now)
+	assert.Equal(t, []QueuedCommand{latest, voltage}, cmds)
+
+	assert.Empty(t, q.cancel(target.ID))
+	assert.Equal(t, []CommandResult{{voltage, ErrCommandCancelled}}, q.cancel(voltage.ID))
+
+	cancelled := q.cancelAll(0x000980)
+	assert.Len(t, cancelled, 1)
+	cmds, _ = q.list(0x000980, now)
+	assert.Empty(t, cmds)
+}
+
+func TestCommandQueueExpiry(t *testing.T) {
+	q := &commandQueue{}
+	now := time.Now()
+	voltage, _ := q.add(0x00097f, Voltage{}, time.Minute, nil, now)
+	q.add(0x00097f, Identify{}, 0, nil, now)
+
+	cmds, _ := q.list(0x00097f, now.Add(time.Minute))
+	assert.Len(t, cmds, 2)
+
+	cmds, results := q.due(0x00097f, 3, now.Add(2*time.Minute))
+	assert.Equal(t, []CommandResult{{voltage, ErrCommandExpired}}, results)
+	if assert.Len(t, cmds, 1) {
+		assert.Equal(t, Identify{}, cmds[0].Record)
+	}
+	// not expecting confirmation, so no longer queued once due
+	cmds, _ = q.list(0x00097f, now)
+	assert.Empty(t, cmds)
+}
+
+func TestCommandQueueDefaultAttempts(t *testing.T) {
+	q := &commandQueue{}
+	now := time.Now()
+	q.add(0x00097f, Voltage{}, 0, nil, now)
+	q.add(0x00097f, Identify{}, 0, nil, now)
+
+	// zero attempts means the default, and only limits confirmable commands
+	for i := 0; i < DefaultCommandAttempts; i += 1 {
+		cmds, results := q.due(0x00097f, 0, now)
+		assert.Empty(t, results)
+		assert.NotEmpty(t, cmds)
+	}
+	cmds, results := q.due(0x00097f, 0, now)
+	assert.Empty(t, cmds)
+	if assert.Len(t, results, 1) {
+		assert.Equal(t, Voltage{}, results[0].Command.Record)
+		assert.Equal(t, ErrNotConfirmed, results[0].Err)
+	}
+}
+
+func TestCommandConfirmation(t *testing.T) {
+	q := &commandQueue{}
+	now := time.Now()
+	q.add(0x00097f, Temperature{21}, 0, nil, now)
+	q.add(0x00097f, Voltage{}, 0, nil, now)
+	report := func(records ...Record) *Message {
+		return &Message{ManuId: energenieManuId, ProdId: eTRVProdId, SensorId: 0x00097f, Records: records}
+	}
+
+	// not yet sent, so not confirmed
+	assert.Empty(t, q.confirm(report(Voltage{3.1})))
+
+	for attempt := 1; attempt <= 2; attempt += 1 {
+		cmds, results := q.due(0x00097f, 2, now)
+		assert.Empty(t, results)
+		if assert.Len(t, cmds, 2) {
+			assert.Equal(t, attempt, cmds[0].Attempts)
+		}
+	}
+
+	results := q.confirm(report(Temperature{19}, Voltage{3.1}))
+	if assert.Len(t, results, 1) {
+		assert.Equal(t, Voltage{}, results[0].Command.Record)
+		assert.NoError(t, results[0].Err)
+	}
+
+	// target temperature not echoed after 2 attempts
+	assert.Empty(t, q.confirm(report(SetTemperature{20})))
+	cmds, results := q.due(0x00097f, 2, now)
+	assert.Empty(t, cmds)
+	if assert.Len(t, results, 1) {
+		assert.Equal(t, Temperature{21}, results[0].Command.Record)
+		assert.Equal(t, ErrNotConfirmed, results[0].Err)
+	}
+}
+
+func TestCommandResultNotify(t *testing.T) {
+	dev := NewDevice()
+	var results []CommandResult
+	dev.OnCommandResult = func(r CommandResult) {
+		results = append(results, r)
+	}
+	ch := make(chan CommandResult, 1)
+	cmd, err := dev.QueueNotify(0x00097f, Diagnostics{}, ch)
+	assert.NoError(t, err)
+	assert.True(t, dev.CancelCommand(cmd.ID))
+	assert.False(t, dev.CancelCommand(cmd.ID))
+
+	expected := CommandResult{cmd, ErrCommandCancelled}
+	assert.Equal(t, expected, <-ch)
+	assert.Equal(t, []CommandResult{expected}, results)
+}
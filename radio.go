@@ -0,0 +1,68 @@
+package ener314
+
+// Radio is the transceiver a Device sends and receives frames with. *HRF,
+// the ENER314-RT, is used unless another is given.
+type Radio interface {
+	// ReceiveFrame returns the next frame received, still encrypted, and its
+	// RSSI in dBm, or nil if none is waiting.
+	ReceiveFrame() ([]byte, float32)
+	// SendFrame transmits an encrypted frame, then returns to receiving.
+	SendFrame(frame []byte) error
+}
+
+// RadioMonitor is implemented by radios, such as *HRF, that can measure the
+// signal strength and their own temperature.
+type RadioMonitor interface {
+	GetRSSI() float32    // dBm
+	GetTemperature() int // °C, approximate
+}
+
+// frame is a frame received and its RSSI.
+type frame struct {
+	data []byte
+	rssi float32
+}
+
+// collectFrames moves any frames waiting in the radio to the inbound queue,
+// so they are not lost switching to transmit. Only frames already complete
+// are saved: one arriving during the transmission is lost. radioMu must be
+// held.
+func (d *Device) collectFrames() {
+	for {
+		data, rssi := d.Radio.ReceiveFrame()
+		if data == nil {
+			return
+		}
+		d.inbound = append(d.inbound, frame{data, rssi})
+	}
+}
+
+// nextFrame returns the next frame received.
+func (d *Device) nextFrame() (frame, bool) {
+	d.radioMu.Lock()
+	defer d.radioMu.Unlock()
+	if d.Radio == nil {
+		return frame{}, false
+	}
+	if len(d.inbound) == 0 {
+		d.collectFrames()
+	}
+	if len(d.inbound) == 0 {
+		return frame{}, false
+	}
+	f := d.inbound[0]
+	d.inbound = d.inbound[1:]
+	return f, true
+}
+
+// sendFrame transmits a frame, once any frames received have been
+// collected.
+func (d *Device) sendFrame(data []byte) error {
+	d.radioMu.Lock()
+	defer d.radioMu.Unlock()
+	if d.Radio == nil {
+		return ErrNotStarted
+	}
+	d.collectFrames()
+	return d.Radio.SendFrame(data)
+}
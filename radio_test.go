@@ -0,0 +1,190 @@
+package ener314
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeRadio is a Radio for tests. Like the ENER314-RT, a frame waiting when
+// transmitting is lost.
+type fakeRadio struct {
+	mu       sync.Mutex
+	received [][]byte
+	sent     [][]byte
+}
+
+func (r *fakeRadio) ReceiveFrame() ([]byte, float32) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.received) == 0 {
+		return nil, 0
+	}
+	data := r.received[0]
+	r.received = r.received[1:]
+	return data, -60
+}
+
+func (r *fakeRadio) SendFrame(frame []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.received = nil
+	r.sent = append(r.sent, frame)
+	return nil
+}
+
+// inject queues frames to be received.
+func (r *fakeRadio) inject(frames ...[]byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.received = append(r.received, frames...)
+}
+
+func (r *fakeRadio) sentFrames() [][]byte {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([][]byte(nil), r.sent...)
+}
+
+func temperatureFrame(sensorId uint32, pip uint16) []byte {
+	message := &Message{
+		ManuId: energenieManuId, ProdId: eTRVProdId, SensorId: sensorId,
+		Records: []Record{Temperature{18.5}},
+	}
+	return Encode(message, NewSequencePipSource(pip))
+}
+
+func TestReceiveAcrossTransmit(t *testing.T) {
+	radio := &fakeRadio{}
+	dev := NewDevice()
+	dev.Radio = radio
+	assert.NoError(t, dev.Start())
+
+	radio.inject(temperatureFrame(0x000001, 1), temperatureFrame(0x000002, 2))
+	assert.NoError(t, dev.Identify(0x000003))
+	assert.Len(t, radio.sentFrames(), 1)
+
+	// the frames waiting when transmitting are still received
+	for _, sensorId := range []uint32{0x000001, 0x000002} {
+		msg := dev.Receive()
+		if assert.NotNil(t, msg) {
+			assert.Equal(t, sensorId, msg.SensorId)
+		}
+	}
+	assert.Nil(t, dev.Receive())
+
+	info, _ := dev.KnownSensor(0x000001)
+	assert.Equal(t, float32(-60), info.RSSI)
+}
+
+func TestConcurrentSendReceive(t *testing.T) {
+	radio := &fakeRadio{}
+	dev := NewDevice()
+	dev.Radio = radio
+	messages := dev.Subscribe(100, OfType(MessageEvent{}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- dev.Run(ctx)
+	}()
+
+	const senders, sends, frames = 4, 10, 20
+	var wg sync.WaitGroup
+	for i := 0; i < senders; i += 1 {
+		wg.Add(1)
+		go func(sensorId uint32) {
+			defer wg.Done()
+			etrv := dev.ETRV(sensorId)
+			for j := 0; j < sends; j += 1 {
+				assert.NoError(t, etrv.SetTargetTemperature(20))
+				etrv.State()
+			}
+		}(uint32(0x000100 + i))
+	}
+	for i := 0; i < frames; i += 1 {
+		radio.inject(temperatureFrame(uint32(0x000200+i), uint16(i)))
+		time.Sleep(time.Millisecond)
+	}
+	wg.Wait()
+
+	received := map[uint32]bool{}
+	for len(received) < frames {
+		select {
+		case e := <-messages.C:
+			received[e.(MessageEvent).Message.SensorId] = true
+		case <-time.After(5 * time.Second):
+			t.Fatalf("received %d of %d frames", len(received), frames)
+		}
+	}
+	assert.Len(t, radio.sentFrames(), senders*sends)
+
+	cancel()
+	assert.Equal(t, context.Canceled, <-done)
+}
+
+func TestZeroDevice(t *testing.T) {
+	radio := &fakeRadio{}
+	dev := &Device{Radio: radio, DetectReplays: true}
+	assert.NoError(t, dev.Start())
+	sub := dev.Subscribe(10)
+
+	// without a duplicate window, repeats are neither suppressed nor replays
+	radio.inject(temperatureFrame(0x000001, 1), temperatureFrame(0x000001, 1))
+	assert.NotNil(t, dev.Receive())
+	if msg := dev.Receive(); assert.NotNil(t, msg) {
+		assert.False(t, msg.Replay)
+	}
+	assert.Equal(t, uint64(0), dev.Duplicates())
+	assert.Equal(t, uint64(2), dev.Stats().FramesReceived)
+	assert.Equal(t, uint64(0), dev.Stats().Replays)
+	assert.Len(t, dev.KnownSensors(), 1)
+	assert.IsType(t, MessageEvent{}, <-sub.C)
+
+	_, err := dev.Queue(0x000001, Identify{})
+	assert.NoError(t, err)
+	dev.ApproveJoin(0x000002)
+	assert.True(t, dev.JoinAllowed(0x000002))
+	assert.NotNil(t, dev.ETRV(0x000003))
+}
+
+func TestRadioMonitor(t *testing.T) {
+	dev := NewDevice()
+	_, err := dev.GetRSSI()
+	assert.Equal(t, ErrNotStarted, err)
+
+	dev.Radio = &fakeRadio{}
+	_, err = dev.GetRSSI()
+	assert.Equal(t, ErrNoMonitor, err)
+	_, err = dev.GetTemperature()
+	assert.Equal(t, ErrNoMonitor, err)
+}
+
+func TestRunDrainsDuplicates(t *testing.T) {
+	radio := &fakeRadio{}
+	dev := NewDevice()
+	dev.Radio = radio
+	messages := dev.Subscribe(10, OfType(MessageEvent{}))
+	frames := [][]byte{}
+	for i := 0; i < 10; i += 1 {
+		frames = append(frames, temperatureFrame(0x000001, 1))
+	}
+	radio.inject(append(frames, temperatureFrame(0x000002, 2))...)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go dev.Run(ctx)
+
+	// duplicates are skipped without waiting to poll for the next frame
+	for _, sensorId := range []uint32{0x000001, 0x000002} {
+		select {
+		case e := <-messages.C:
+			assert.Equal(t, sensorId, e.(MessageEvent).Message.SensorId)
+		case <-time.After(5 * receivePollInterval):
+			t.Fatal("frames after duplicates not received promptly")
+		}
+	}
+}
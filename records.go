@@ -1,6 +1,7 @@
 package ener314
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 )
@@ -15,6 +16,72 @@ type Record interface {
 	Encode(buf ByteAndBytesWriter)
 }
 
+// ParameterRecord is a Record that describes its parameter, as all the records
+// in this package do.
+type ParameterRecord interface {
+	Record
+	Parameter() Parameter
+}
+
+// RecordParameter returns the description of a record's parameter, from the
+// parameter id it encodes for records that do not describe themselves.
+func RecordParameter(record Record) Parameter {
+	if r, ok := record.(ParameterRecord); ok {
+		return r.Parameter()
+	}
+	var buf bytes.Buffer
+	record.Encode(&buf)
+	if buf.Len() == 0 {
+		return Parameter{}
+	}
+	return parameter(buf.Bytes()[0])
+}
+
+func init() {
+	// Reports
+	RegisterRecord(OT_JOIN_CMD, func(Value) (Record, error) { return Join{}, nil }, nil)
+	RegisterRecord(OT_ALARM, UintDecoder(func(v uint16) Record { return Alarm{v} }), UintEncoder(func(v uint16) Record { return Alarm{v} }))
+	RegisterRecord(OT_DEBUG_OUTPUT, StringDecoder(func(v string) Record { return DebugOutput{v} }), StringEncoder(func(v string) Record { return DebugOutput{v} }))
+	RegisterRecord(OT_REPORT_DIAGNOSTICS, UintDecoder(func(v uint16) Record { return Diagnostics{v} }), nil)
+	RegisterRecord(OT_WATER_DETECTOR, BoolDecoder(func(v bool) Record { return WaterDetector{v} }), BoolEncoder(func(v bool) Record { return WaterDetector{v} }))
+	RegisterRecord(OT_REPORT_VOLTAGE, FloatDecoder(func(v float64) Record { return BatteryVoltage{v} }), FloatEncoder(func(v float64) Record { return BatteryVoltage{v} }))
+	RegisterRecord(OT_DOOR_SENSOR, BoolDecoder(func(v bool) Record { return DoorSensor{v} }), BoolEncoder(func(v bool) Record { return DoorSensor{v} }))
+	RegisterRecord(OT_FREQUENCY, FloatDecoder(func(v float64) Record { return Frequency{v} }), FloatEncoder(func(v float64) Record { return Frequency{v} }))
+	RegisterRecord(OT_HUMIDITY, FloatDecoder(func(v float64) Record { return Humidity{v} }), FloatEncoder(func(v float64) Record { return Humidity{v} }))
+	RegisterRecord(OT_CURRENT, FloatDecoder(func(v float64) Record { return Current{v} }), FloatEncoder(func(v float64) Record { return Current{v} }))
+	RegisterRecord(OT_LIGHT_LEVEL, FloatDecoder(func(v float64) Record { return LightLevel{v} }), FloatEncoder(func(v float64) Record { return LightLevel{v} }))
+	RegisterRecord(OT_MOTION_DETECTOR, BoolDecoder(func(v bool) Record { return MotionDetector{v} }), BoolEncoder(func(v bool) Record { return MotionDetector{v} }))
+	RegisterRecord(OT_POWER_FACTOR, FloatDecoder(func(v float64) Record { return PowerFactor{v} }), FloatEncoder(func(v float64) Record { return PowerFactor{v} }))
+	RegisterRecord(OT_OCCUPANCY, BoolDecoder(func(v bool) Record { return Occupancy{v} }), BoolEncoder(func(v bool) Record { return Occupancy{v} }))
+	RegisterRecord(OT_POWER, FloatDecoder(func(v float64) Record { return RealPower{v} }), FloatEncoder(func(v float64) Record { return RealPower{v} }))
+	RegisterRecord(OT_REACTIVE_P, FloatDecoder(func(v float64) Record { return ReactivePower{v} }), FloatEncoder(func(v float64) Record { return ReactivePower{v} }))
+	RegisterRecord(OT_ROTATION_SPEED, FloatDecoder(func(v float64) Record { return RotationSpeed{v} }), FloatEncoder(func(v float64) Record { return RotationSpeed{v} }))
+	RegisterRecord(OT_SW_STATE, BoolDecoder(func(v bool) Record { return SwitchState{v} }), BoolEncoder(func(v bool) Record { return SwitchState{v} }))
+	RegisterRecord(OT_TEMP_REPORT, FloatDecoder(func(v float64) Record { return Temperature{v} }), nil)
+	RegisterRecord(OT_VOLTAGE, FloatDecoder(func(v float64) Record { return Voltage{v} }), nil)
+	RegisterRecord(OT_WATER_FLOW_RATE, FloatDecoder(func(v float64) Record { return WaterFlowRate{v} }), FloatEncoder(func(v float64) Record { return WaterFlowRate{v} }))
+	RegisterRecord(OT_WATER_PRESSURE, FloatDecoder(func(v float64) Record { return WaterPressure{v} }), FloatEncoder(func(v float64) Record { return WaterPressure{v} }))
+	RegisterRecord(OT_PHASE1_POWER, FloatDecoder(func(v float64) Record { return Phase1Power{v} }), FloatEncoder(func(v float64) Record { return Phase1Power{v} }))
+	RegisterRecord(OT_PHASE2_POWER, FloatDecoder(func(v float64) Record { return Phase2Power{v} }), FloatEncoder(func(v float64) Record { return Phase2Power{v} }))
+	RegisterRecord(OT_PHASE3_POWER, FloatDecoder(func(v float64) Record { return Phase3Power{v} }), FloatEncoder(func(v float64) Record { return Phase3Power{v} }))
+	RegisterRecord(OT_3PHASE_POWER, FloatDecoder(func(v float64) Record { return ThreePhasePower{v} }), FloatEncoder(func(v float64) Record { return ThreePhasePower{v} }))
+	RegisterRecord(OT_TEST, UintDecoder(func(v uint16) Record { return Test{v} }), UintEncoder(func(v uint16) Record { return Test{v} }))
+
+	// Commands
+	RegisterRecord(OT_JOIN_RESP, func(Value) (Record, error) { return JoinReport{}, nil }, FloatEncoder(func(float64) Record { return JoinReport{} }))
+	RegisterRecord(OT_IDENTIFY, func(Value) (Record, error) { return Identify{}, nil }, FloatEncoder(func(float64) Record { return Identify{} }))
+	RegisterRecord(OT_EXERCISE_VALVE, func(Value) (Record, error) { return ExerciseValve{}, nil }, FloatEncoder(func(float64) Record { return ExerciseValve{} }))
+	RegisterRecord(OT_REQUEST_VOLTAGE, nil, FloatEncoder(func(float64) Record { return Voltage{} }))
+	RegisterRecord(OT_REQUEST_DIAGNOSTICS, nil, FloatEncoder(func(float64) Record { return Diagnostics{} }))
+	RegisterRecord(OT_TEMP_SET, FloatDecoder(func(v float64) Record { return SetTemperature{v} }), FloatEncoder(func(v float64) Record { return SetTemperature{v} }))
+	RegisterRecord(OT_SET_REPORTING_INTERVAL, UintDecoder(func(v uint16) Record { return ReportInterval{v} }), UintEncoder(func(v uint16) Record { return ReportInterval{v} }))
+	RegisterRecord(OT_SET_VALVE_STATE, UintDecoder(func(v uint16) Record { return SetValveState{ValveState(v)} }), UintEncoder(func(v uint16) Record { return SetValveState{ValveState(v)} }))
+	RegisterRecord(OT_SET_LOW_POWER_MODE, UintDecoder(func(v uint16) Record { return SetPowerMode{PowerMode(v)} }), UintEncoder(func(v uint16) Record { return SetPowerMode{PowerMode(v)} }))
+	RegisterRecord(OT_ACTUATE_SW, BoolDecoder(func(v bool) Record { return ActuateSwitch{v} }), BoolEncoder(func(v bool) Record { return ActuateSwitch{v} }))
+	RegisterRecord(OT_SOURCE_SELECTOR, UintDecoder(func(v uint16) Record { return SourceSelector{v} }), UintEncoder(func(v uint16) Record { return SourceSelector{v} }))
+	RegisterRecord(OT_GLOBAL_READ, func(Value) (Record, error) { return GlobalRead{}, nil }, FloatEncoder(func(float64) Record { return GlobalRead{} }))
+}
+
 type Join struct{}
 
 func (j Join) String() string {
@@ -26,6 +93,10 @@ func (j Join) Encode(buf ByteAndBytesWriter) {
 	buf.WriteByte(0)
 }
 
+func (j Join) Parameter() Parameter {
+	return parameter(OT_JOIN_CMD)
+}
+
 type Temperature struct {
 	Value float64
 }
@@ -39,6 +110,10 @@ func (t Temperature) Encode(buf ByteAndBytesWriter) {
 	buf.Write(encodeFloat64(ENC_SFPp8, t.Value))
 }
 
+func (t Temperature) Parameter() Parameter {
+	return parameter(OT_TEMP_REPORT)
+}
+
 type SetTemperature struct {
 	Value float64
 }
@@ -52,6 +127,10 @@ func (t SetTemperature) Encode(buf ByteAndBytesWriter) {
 	buf.Write(encodeFloat64(ENC_SFPp8, t.Value))
 }
 
+func (t SetTemperature) Parameter() Parameter {
+	return parameter(OT_TEMP_SET)
+}
+
 type Voltage struct {
 	Value float64
 }
@@ -65,6 +144,10 @@ func (v Voltage) Encode(buf ByteAndBytesWriter) {
 	buf.WriteByte(0)
 }
 
+func (v Voltage) Parameter() Parameter {
+	return parameter(OT_VOLTAGE)
+}
+
 var DiagnosticTable = []string{
 	// From LSB bit, LSB byte:
 	"Motor current below expectation",
@@ -90,14 +173,19 @@ type Diagnostics struct {
 	Value uint16
 }
 
-func (v Diagnostics) String() string {
+// Flags returns the description of each flag set, from DiagnosticTable.
+func (v Diagnostics) Flags() []string {
 	var messages []string
 	for i, text := range DiagnosticTable {
 		if v.Value&(1<<uint(i)) != 0 {
 			messages = append(messages, text)
 		}
 	}
-	return fmt.Sprintf("Diagnostics{%d,%s}", v.Value, messages)
+	return messages
+}
+
+func (v Diagnostics) String() string {
+	return fmt.Sprintf("Diagnostics{%d,%s}", v.Value, v.Flags())
 }
 
 func (v Diagnostics) Encode(buf ByteAndBytesWriter) {
@@ -105,6 +193,10 @@ func (v Diagnostics) Encode(buf ByteAndBytesWriter) {
 	buf.WriteByte(0)
 }
 
+func (v Diagnostics) Parameter() Parameter {
+	return parameter(OT_REPORT_DIAGNOSTICS)
+}
+
 type UnhandledRecord struct {
 	ID    byte
 	Type  byte
@@ -116,7 +208,37 @@ func (t UnhandledRecord) String() string {
 }
 
 func (t UnhandledRecord) Encode(buf ByteAndBytesWriter) {
-	// Unhandled
+	// resend as received
+	buf.WriteByte(t.ID)
+	buf.WriteByte(t.Type)
+	buf.Write(t.Value)
+}
+
+func (t UnhandledRecord) Parameter() Parameter {
+	return parameter(t.ID)
+}
+
+// NewUnhandledRecord is any parameter and value, for sending parameters
+// without a typed record.
+func NewUnhandledRecord(paramId byte, value Value) UnhandledRecord {
+	return UnhandledRecord{paramId, value.TypeDesc(), value.Bytes()}
+}
+
+// RawValue is the value as received.
+func (t UnhandledRecord) RawValue() Value {
+	return DecodeValue(t.Type, t.Value)
+}
+
+// validate checks the record encodes as it describes itself: with as many
+// bytes as the length in its type descriptor.
+func (t UnhandledRecord) validate() error {
+	if err := t.RawValue().Validate(); err != nil {
+		return err
+	}
+	if int(t.Type&0x0f) != len(t.Value) {
+		return ErrLengthMismatch
+	}
+	return nil
 }
 
 // Commands
@@ -132,6 +254,10 @@ func (i Identify) Encode(buf ByteAndBytesWriter) {
 	buf.WriteByte(0)
 }
 
+func (i Identify) Parameter() Parameter {
+	return parameter(OT_IDENTIFY)
+}
+
 type JoinReport struct{}
 
 func (i JoinReport) String() string {
@@ -143,6 +269,10 @@ func (i JoinReport) Encode(buf ByteAndBytesWriter) {
 	buf.WriteByte(0)
 }
 
+func (i JoinReport) Parameter() Parameter {
+	return parameter(OT_JOIN_RESP)
+}
+
 type ExerciseValve struct{}
 
 func (v ExerciseValve) String() string {
@@ -154,12 +284,16 @@ func (v ExerciseValve) Encode(buf ByteAndBytesWriter) {
 	buf.WriteByte(0)
 }
 
+func (v ExerciseValve) Parameter() Parameter {
+	return parameter(OT_EXERCISE_VALVE)
+}
+
 type ReportInterval struct {
 	Value uint16
 }
 
 func (v ReportInterval) String() string {
-	return "ReportInterval"
+	return fmt.Sprintf("ReportInterval{%d}", v.Value)
 }
 
 func (v ReportInterval) Encode(buf ByteAndBytesWriter) {
@@ -167,12 +301,16 @@ func (v ReportInterval) Encode(buf ByteAndBytesWriter) {
 	buf.Write(encodeInteger(ENC_UINT, uint32(v.Value)))
 }
 
+func (v ReportInterval) Parameter() Parameter {
+	return parameter(OT_SET_REPORTING_INTERVAL)
+}
+
 type SetValveState struct {
 	State ValveState
 }
 
 func (v SetValveState) String() string {
-	return "SetValveState"
+	return fmt.Sprintf("SetValveState{%s}", v.State)
 }
 
 func (v SetValveState) Encode(buf ByteAndBytesWriter) {
@@ -180,15 +318,471 @@ func (v SetValveState) Encode(buf ByteAndBytesWriter) {
 	buf.Write(encodeInteger(ENC_UINT, uint32(v.State)))
 }
 
+func (v SetValveState) Parameter() Parameter {
+	return parameter(OT_SET_VALVE_STATE)
+}
+
 type SetPowerMode struct {
 	Mode PowerMode
 }
 
 func (v SetPowerMode) String() string {
-	return "SetPowerMode"
+	return fmt.Sprintf("SetPowerMode{%s}", v.Mode)
 }
 
 func (v SetPowerMode) Encode(buf ByteAndBytesWriter) {
 	buf.WriteByte(OT_SET_LOW_POWER_MODE)
 	buf.Write(encodeInteger(ENC_UINT, uint32(v.Mode)))
 }
+
+func (v SetPowerMode) Parameter() Parameter {
+	return parameter(OT_SET_LOW_POWER_MODE)
+}
+
+type ActuateSwitch struct {
+	On bool
+}
+
+func (v ActuateSwitch) String() string {
+	return fmt.Sprintf("ActuateSwitch{%t}", v.On)
+}
+
+func (v ActuateSwitch) Encode(buf ByteAndBytesWriter) {
+	encodeBool(buf, OT_ACTUATE_SW, v.On)
+}
+
+func (v ActuateSwitch) Parameter() Parameter {
+	return parameter(OT_ACTUATE_SW)
+}
+
+// SourceSelector selects a source.
+type SourceSelector struct {
+	Value uint16
+}
+
+func (s SourceSelector) String() string {
+	return fmt.Sprintf("SourceSelector{%d}", s.Value)
+}
+
+func (s SourceSelector) Encode(buf ByteAndBytesWriter) {
+	buf.WriteByte(OT_SOURCE_SELECTOR)
+	buf.Write(encodeInteger(ENC_UINT, uint32(s.Value)))
+}
+
+func (s SourceSelector) Parameter() Parameter {
+	return parameter(OT_SOURCE_SELECTOR)
+}
+
+// GlobalRead asks a device to report all its parameters.
+type GlobalRead struct{}
+
+func (g GlobalRead) String() string {
+	return "GlobalRead"
+}
+
+func (g GlobalRead) Encode(buf ByteAndBytesWriter) {
+	buf.WriteByte(OT_GLOBAL_READ)
+	buf.WriteByte(0)
+}
+
+func (g GlobalRead) Parameter() Parameter {
+	return parameter(OT_GLOBAL_READ)
+}
+
+// Reports
+
+// Test is a test value, sent in either direction.
+type Test struct {
+	Value uint16
+}
+
+func (t Test) String() string {
+	return fmt.Sprintf("Test{%d}", t.Value)
+}
+
+func (t Test) Encode(buf ByteAndBytesWriter) {
+	buf.WriteByte(OT_TEST)
+	buf.Write(encodeInteger(ENC_UINT, uint32(t.Value)))
+}
+
+func (t Test) Parameter() Parameter {
+	return parameter(OT_TEST)
+}
+
+type Alarm struct {
+	Value uint16
+}
+
+func (a Alarm) String() string {
+	return fmt.Sprintf("Alarm{%d}", a.Value)
+}
+
+func (a Alarm) Encode(buf ByteAndBytesWriter) {
+	buf.WriteByte(OT_ALARM)
+	buf.Write(encodeInteger(ENC_UINT, uint32(a.Value)))
+}
+
+func (a Alarm) Parameter() Parameter {
+	return parameter(OT_ALARM)
+}
+
+type DebugOutput struct {
+	Text string
+}
+
+func (d DebugOutput) String() string {
+	return fmt.Sprintf("DebugOutput{%q}", d.Text)
+}
+
+func (d DebugOutput) Encode(buf ByteAndBytesWriter) {
+	v := StringValue(d.Text)
+	buf.WriteByte(OT_DEBUG_OUTPUT)
+	buf.WriteByte(v.TypeDesc())
+	buf.Write(v.Raw)
+}
+
+func (d DebugOutput) Parameter() Parameter {
+	return parameter(OT_DEBUG_OUTPUT)
+}
+
+func (d DebugOutput) validate() error {
+	return StringValue(d.Text).Validate()
+}
+
+type BatteryVoltage struct {
+	Value float64
+}
+
+func (v BatteryVoltage) String() string {
+	return fmt.Sprintf("BatteryVoltage{%f}", v.Value)
+}
+
+func (v BatteryVoltage) Encode(buf ByteAndBytesWriter) {
+	encodeReport(buf, OT_REPORT_VOLTAGE, v.Value)
+}
+
+func (v BatteryVoltage) Parameter() Parameter {
+	return parameter(OT_REPORT_VOLTAGE)
+}
+
+type WaterDetector struct {
+	Detected bool
+}
+
+func (w WaterDetector) String() string {
+	return fmt.Sprintf("WaterDetector{%t}", w.Detected)
+}
+
+func (w WaterDetector) Encode(buf ByteAndBytesWriter) {
+	encodeBool(buf, OT_WATER_DETECTOR, w.Detected)
+}
+
+func (w WaterDetector) Parameter() Parameter {
+	return parameter(OT_WATER_DETECTOR)
+}
+
+type DoorSensor struct {
+	Open bool
+}
+
+func (d DoorSensor) String() string {
+	return fmt.Sprintf("DoorSensor{%t}", d.Open)
+}
+
+func (d DoorSensor) Encode(buf ByteAndBytesWriter) {
+	encodeBool(buf, OT_DOOR_SENSOR, d.Open)
+}
+
+func (d DoorSensor) Parameter() Parameter {
+	return parameter(OT_DOOR_SENSOR)
+}
+
+type Frequency struct {
+	Value float64
+}
+
+func (f Frequency) String() string {
+	return fmt.Sprintf("Frequency{%f}", f.Value)
+}
+
+func (f Frequency) Encode(buf ByteAndBytesWriter) {
+	encodeReport(buf, OT_FREQUENCY, f.Value)
+}
+
+func (f Frequency) Parameter() Parameter {
+	return parameter(OT_FREQUENCY)
+}
+
+type Humidity struct {
+	Value float64
+}
+
+func (h Humidity) String() string {
+	return fmt.Sprintf("Humidity{%f}", h.Value)
+}
+
+func (h Humidity) Encode(buf ByteAndBytesWriter) {
+	encodeReport(buf, OT_HUMIDITY, h.Value)
+}
+
+func (h Humidity) Parameter() Parameter {
+	return parameter(OT_HUMIDITY)
+}
+
+type Current struct {
+	Value float64
+}
+
+func (c Current) String() string {
+	return fmt.Sprintf("Current{%f}", c.Value)
+}
+
+func (c Current) Encode(buf ByteAndBytesWriter) {
+	encodeReport(buf, OT_CURRENT, c.Value)
+}
+
+func (c Current) Parameter() Parameter {
+	return parameter(OT_CURRENT)
+}
+
+type LightLevel struct {
+	Value float64
+}
+
+func (l LightLevel) String() string {
+	return fmt.Sprintf("LightLevel{%f}", l.Value)
+}
+
+func (l LightLevel) Encode(buf ByteAndBytesWriter) {
+	encodeReport(buf, OT_LIGHT_LEVEL, l.Value)
+}
+
+func (l LightLevel) Parameter() Parameter {
+	return parameter(OT_LIGHT_LEVEL)
+}
+
+type MotionDetector struct {
+	Motion bool
+}
+
+func (m MotionDetector) String() string {
+	return fmt.Sprintf("MotionDetector{%t}", m.Motion)
+}
+
+func (m MotionDetector) Encode(buf ByteAndBytesWriter) {
+	encodeBool(buf, OT_MOTION_DETECTOR, m.Motion)
+}
+
+func (m MotionDetector) Parameter() Parameter {
+	return parameter(OT_MOTION_DETECTOR)
+}
+
+type PowerFactor struct {
+	Value float64
+}
+
+func (p PowerFactor) String() string {
+	return fmt.Sprintf("PowerFactor{%f}", p.Value)
+}
+
+func (p PowerFactor) Encode(buf ByteAndBytesWriter) {
+	encodeReport(buf, OT_POWER_FACTOR, p.Value)
+}
+
+func (p PowerFactor) Parameter() Parameter {
+	return parameter(OT_POWER_FACTOR)
+}
+
+type Occupancy struct {
+	Occupied bool
+}
+
+func (o Occupancy) String() string {
+	return fmt.Sprintf("Occupancy{%t}", o.Occupied)
+}
+
+func (o Occupancy) Encode(buf ByteAndBytesWriter) {
+	encodeBool(buf, OT_OCCUPANCY, o.Occupied)
+}
+
+func (o Occupancy) Parameter() Parameter {
+	return parameter(OT_OCCUPANCY)
+}
+
+type RealPower struct {
+	Value float64
+}
+
+func (p RealPower) String() string {
+	return fmt.Sprintf("RealPower{%f}", p.Value)
+}
+
+func (p RealPower) Encode(buf ByteAndBytesWriter) {
+	encodeReport(buf, OT_POWER, p.Value)
+}
+
+func (p RealPower) Parameter() Parameter {
+	return parameter(OT_POWER)
+}
+
+type ReactivePower struct {
+	Value float64
+}
+
+func (p ReactivePower) String() string {
+	return fmt.Sprintf("ReactivePower{%f}", p.Value)
+}
+
+func (p ReactivePower) Encode(buf ByteAndBytesWriter) {
+	encodeReport(buf, OT_REACTIVE_P, p.Value)
+}
+
+func (p ReactivePower) Parameter() Parameter {
+	return parameter(OT_REACTIVE_P)
+}
+
+type RotationSpeed struct {
+	Value float64
+}
+
+func (r RotationSpeed) String() string {
+	return fmt.Sprintf("RotationSpeed{%f}", r.Value)
+}
+
+func (r RotationSpeed) Encode(buf ByteAndBytesWriter) {
+	encodeReport(buf, OT_ROTATION_SPEED, r.Value)
+}
+
+func (r RotationSpeed) Parameter() Parameter {
+	return parameter(OT_ROTATION_SPEED)
+}
+
+type SwitchState struct {
+	On bool
+}
+
+func (s SwitchState) String() string {
+	return fmt.Sprintf("SwitchState{%t}", s.On)
+}
+
+func (s SwitchState) Encode(buf ByteAndBytesWriter) {
+	encodeBool(buf, OT_SW_STATE, s.On)
+}
+
+func (s SwitchState) Parameter() Parameter {
+	return parameter(OT_SW_STATE)
+}
+
+type WaterFlowRate struct {
+	Value float64
+}
+
+func (w WaterFlowRate) String() string {
+	return fmt.Sprintf("WaterFlowRate{%f}", w.Value)
+}
+
+func (w WaterFlowRate) Encode(buf ByteAndBytesWriter) {
+	encodeReport(buf, OT_WATER_FLOW_RATE, w.Value)
+}
+
+func (w WaterFlowRate) Parameter() Parameter {
+	return parameter(OT_WATER_FLOW_RATE)
+}
+
+type WaterPressure struct {
+	Value float64
+}
+
+func (w WaterPressure) String() string {
+	return fmt.Sprintf("WaterPressure{%f}", w.Value)
+}
+
+func (w WaterPressure) Encode(buf ByteAndBytesWriter) {
+	encodeReport(buf, OT_WATER_PRESSURE, w.Value)
+}
+
+func (w WaterPressure) Parameter() Parameter {
+	return parameter(OT_WATER_PRESSURE)
+}
+
+// Phase1Power is the real power on phase 1 of a three phase supply.
+type Phase1Power struct {
+	Value float64
+}
+
+func (p Phase1Power) String() string {
+	return fmt.Sprintf("Phase1Power{%f}", p.Value)
+}
+
+func (p Phase1Power) Encode(buf ByteAndBytesWriter) {
+	encodeReport(buf, OT_PHASE1_POWER, p.Value)
+}
+
+func (p Phase1Power) Parameter() Parameter {
+	return parameter(OT_PHASE1_POWER)
+}
+
+// Phase2Power is the real power on phase 2 of a three phase supply.
+type Phase2Power struct {
+	Value float64
+}
+
+func (p Phase2Power) String() string {
+	return fmt.Sprintf("Phase2Power{%f}", p.Value)
+}
+
+func (p Phase2Power) Encode(buf ByteAndBytesWriter) {
+	encodeReport(buf, OT_PHASE2_POWER, p.Value)
+}
+
+func (p Phase2Power) Parameter() Parameter {
+	return parameter(OT_PHASE2_POWER)
+}
+
+// Phase3Power is the real power on phase 3 of a three phase supply.
+type Phase3Power struct {
+	Value float64
+}
+
+func (p Phase3Power) String() string {
+	return fmt.Sprintf("Phase3Power{%f}", p.Value)
+}
+
+func (p Phase3Power) Encode(buf ByteAndBytesWriter) {
+	encodeReport(buf, OT_PHASE3_POWER, p.Value)
+}
+
+func (p Phase3Power) Parameter() Parameter {
+	return parameter(OT_PHASE3_POWER)
+}
+
+type ThreePhasePower struct {
+	Value float64
+}
+
+func (p ThreePhasePower) String() string {
+	return fmt.Sprintf("ThreePhasePower{%f}", p.Value)
+}
+
+func (p ThreePhasePower) Encode(buf ByteAndBytesWriter) {
+	encodeReport(buf, OT_3PHASE_POWER, p.Value)
+}
+
+func (p ThreePhasePower) Parameter() Parameter {
+	return parameter(OT_3PHASE_POWER)
+}
+
+// encodeReport writes a report with the parameter's usual encoding.
+func encodeReport(buf ByteAndBytesWriter, id byte, value float64) {
+	buf.WriteByte(id)
+	buf.Write(encodeFloat64(parameter(id).Encoding, value))
+}
+
+func encodeBool(buf ByteAndBytesWriter, id byte, value bool) {
+	var v uint32
+	if value {
+		v = 1
+	}
+	buf.WriteByte(id)
+	buf.Write(encodeInteger(ENC_UINT, v))
+}
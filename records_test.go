@@ -21,6 +21,18 @@ var testEncodingCases = []struct {
 	{ReportInterval{300}, []byte{'R' | 0x80, 0x02, 0x01, 0x2c}},
 	{SetValveState{VALVE_STATE_AUTO}, []byte{'%' | 0x80, 0x01, 0x02}},
 	{SetPowerMode{POWER_MODE_LOW}, []byte{'$' | 0x80, 0x01, 0x01}},
+	{RealPower{100}, []byte{'p', 0x81, 0x64}},
+	{Frequency{50}, []byte{'f', 0x22, 0x32, 0x00}},
+	{SwitchState{true}, []byte{'s', 0x01, 0x01}},
+	{ActuateSwitch{false}, []byte{'s' | 0x80, 0x01, 0x00}},
+	{Phase2Power{300}, []byte{'z', 0x82, 0x01, 0x2c}},
+	{Test{5}, []byte{0xaa, 0x01, 0x05}},
+	{SourceSelector{2}, []byte{0xc0, 0x01, 0x02}},
+	{GlobalRead{}, []byte{0xe1, 0x00}},
+	{NewUnhandledRecord(0xa7, UintValue(3)), []byte{0xa7, 0x01, 0x03}},
+	{NewUnhandledRecord(0xa8, Value{ENC_SFPp8, []byte{0x12, 0x80}}), []byte{0xa8, 0x92, 0x12, 0x80}},
+	{NewUnhandledRecord(0xa9, Value{ENC_UINT, nil}), []byte{0xa9, 0x00}},
+	{UnhandledRecord{0x50, 0x12, []byte{0x12, 0x80}}, []byte{0x50, 0x12, 0x12, 0x80}},
 }
 
 func TestEncoding(t *testing.T) {
@@ -30,3 +42,47 @@ func TestEncoding(t *testing.T) {
 		assert.Equal(t, tt.encoding, buf.Bytes())
 	}
 }
+
+func TestParameters(t *testing.T) {
+	seen := map[byte]bool{}
+	for _, p := range Parameters {
+		assert.False(t, seen[p.ID], "duplicate parameter %02x", p.ID)
+		seen[p.ID] = true
+		assert.NotEmpty(t, p.Name)
+	}
+
+	p, ok := LookupParameter(OT_POWER)
+	assert.True(t, ok)
+	assert.Equal(t, "REAL_POWER", p.Name)
+	assert.Equal(t, "W", p.Unit)
+	assert.Equal(t, DIRECTION_REPORT, p.Direction)
+
+	_, ok = LookupParameter(0x50)
+	assert.False(t, ok)
+	assert.Equal(t, "UNKNOWN_50", UnhandledRecord{ID: 0x50}.Parameter().Name)
+	assert.Equal(t, "REAL_POWER", RecordParameter(RealPower{100}).Name)
+	assert.Equal(t, "HUMIDITY", RecordParameter(plainRecord{}).Name)
+}
+
+// plainRecord is a Record which does not describe its parameter.
+type plainRecord struct{}
+
+func (plainRecord) String() string { return "plain" }
+
+func (plainRecord) Encode(buf ByteAndBytesWriter) {
+	buf.Write([]byte{OT_HUMIDITY, 0x00})
+}
+
+func TestCommandStrings(t *testing.T) {
+	assert.Equal(t, "ReportInterval{300}", ReportInterval{300}.String())
+	assert.Equal(t, "SetValveState{closed}", SetValveState{VALVE_STATE_CLOSED}.String())
+	assert.Equal(t, "SetPowerMode{low}", SetPowerMode{POWER_MODE_LOW}.String())
+	assert.Equal(t, "SetPowerMode{PowerMode(7)}", SetPowerMode{7}.String())
+}
+
+func TestParametersHaveRecords(t *testing.T) {
+	for _, p := range Parameters {
+		codec := lookupCodec(0, 0, p.ID)
+		assert.True(t, codec.decode != nil || codec.encode != nil, p.Name)
+	}
+}
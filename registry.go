@@ -0,0 +1,211 @@
+package ener314
+
+import (
+	"math"
+	"sync"
+)
+
+// A RecordDecoder decodes the value of a received parameter into a Record.
+type RecordDecoder func(value Value) (Record, error)
+
+// A RecordEncoder builds the Record that is sent for a parameter with the
+// given value. The bytes sent are those written by the Record's Encode, so an
+// encoder controls the wire format by returning a Record that encodes the way
+// it wants, for example an UnhandledRecord of already encoded bytes.
+type RecordEncoder func(value Value) (Record, error)
+
+type recordKey struct {
+	manuId, prodId byte
+	paramId        byte
+	scoped         bool
+}
+
+type recordCodec struct {
+	decode RecordDecoder
+	encode RecordEncoder
+}
+
+var (
+	registryLock sync.RWMutex
+	registry     = map[recordKey]recordCodec{}
+)
+
+// RegisterRecord registers the decoder and encoder for a parameter id for
+// all products. Either may be nil. Registering an id again replaces the
+// previous registration.
+func RegisterRecord(paramId byte, decode RecordDecoder, encode RecordEncoder) {
+	register(recordKey{paramId: paramId}, decode, encode)
+}
+
+// RegisterProductRecord registers the decoder and encoder for a parameter id
+// sent by or to a single manufacturer/product. These take precedence over
+// registrations made with RegisterRecord.
+func RegisterProductRecord(manuId, prodId, paramId byte, decode RecordDecoder, encode RecordEncoder) {
+	register(recordKey{manuId, prodId, paramId, true}, decode, encode)
+}
+
+func register(key recordKey, decode RecordDecoder, encode RecordEncoder) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	registry[key] = recordCodec{decode, encode}
+}
+
+// UnregisterRecord removes the registration made with RegisterRecord for a
+// parameter id, leaving it to decode as an UnhandledRecord.
+func UnregisterRecord(paramId byte) {
+	unregister(recordKey{paramId: paramId})
+}
+
+// UnregisterProductRecord removes a registration made with
+// RegisterProductRecord.
+func UnregisterProductRecord(manuId, prodId, paramId byte) {
+	unregister(recordKey{manuId, prodId, paramId, true})
+}
+
+func unregister(key recordKey) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	delete(registry, key)
+}
+
+func lookupCodec(manuId, prodId, paramId byte) recordCodec {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+	if codec, ok := registry[recordKey{manuId, prodId, paramId, true}]; ok {
+		return codec
+	}
+	return registry[recordKey{paramId: paramId}]
+}
+
+// valueRequired are the parameters for which a packet with an empty value is
+// rejected. Other parameters with empty values decode as an UnhandledRecord.
+var valueRequired = map[byte]bool{
+	OT_TEMP_REPORT:        true,
+	OT_VOLTAGE:            true,
+	OT_REPORT_DIAGNOSTICS: true,
+}
+
+// decodeRecord decodes a parameter with the registered decoder, falling back
+// to an UnhandledRecord.
+func decodeRecord(manuId, prodId, paramId, typeDesc byte, value []byte) (Record, error) {
+	unhandled := UnhandledRecord{paramId, typeDesc, append([]byte(nil), value...)}
+	codec := lookupCodec(manuId, prodId, paramId)
+	if codec.decode == nil {
+		return unhandled, nil
+	}
+	record, err := codec.decode(DecodeValue(typeDesc, value))
+	if err == ErrShortPacket && len(value) == 0 && !valueRequired[paramId] {
+		return unhandled, nil
+	}
+	return record, err
+}
+
+// NewRecord builds the Record for a parameter with the given value, using the
+// encoder registered for the manufacturer/product. value may be a Value, or a
+// Go value which is encoded with the parameter's usual encoding.
+func NewRecord(manuId, prodId, paramId byte, value interface{}) (Record, error) {
+	codec := lookupCodec(manuId, prodId, paramId)
+	if codec.encode == nil {
+		return nil, ErrNoEncoder
+	}
+	v, err := NewValue(parameter(paramId).Encoding, value)
+	if err != nil {
+		return nil, err
+	}
+	return codec.encode(v)
+}
+
+// FloatDecoder returns a RecordDecoder for numeric parameters.
+func FloatDecoder(fn func(float64) Record) RecordDecoder {
+	return func(value Value) (Record, error) {
+		if err := checkNumeric(value); err != nil {
+			return nil, err
+		}
+		return fn(value.Float64()), nil
+	}
+}
+
+// checkNumeric checks a received value can be read as a number: that it is
+// not empty, and IEEE754 values are single or double precision.
+func checkNumeric(value Value) error {
+	if len(value.Raw) == 0 {
+		return ErrShortPacket
+	}
+	return value.Validate()
+}
+
+// UintDecoder returns a RecordDecoder for unsigned integer parameters,
+// failing with ErrValueRange for values that are not a uint16.
+func UintDecoder(fn func(uint16) Record) RecordDecoder {
+	return func(value Value) (Record, error) {
+		if err := checkNumeric(value); err != nil {
+			return nil, err
+		}
+		u, err := uint16Value(value)
+		if err != nil {
+			return nil, err
+		}
+		return fn(u), nil
+	}
+}
+
+// uint16Value is a value as a uint16, or ErrValueRange if it is negative,
+// fractional or too large.
+func uint16Value(value Value) (uint16, error) {
+	f := value.Float64()
+	if f < 0 || f > math.MaxUint16 || f != math.Trunc(f) {
+		return 0, ErrValueRange
+	}
+	return uint16(f), nil
+}
+
+// BoolDecoder returns a RecordDecoder for on/off parameters.
+func BoolDecoder(fn func(bool) Record) RecordDecoder {
+	return func(value Value) (Record, error) {
+		if err := checkNumeric(value); err != nil {
+			return nil, err
+		}
+		return fn(value.Float64() != 0), nil
+	}
+}
+
+// StringDecoder returns a RecordDecoder for text parameters.
+func StringDecoder(fn func(string) Record) RecordDecoder {
+	return func(value Value) (Record, error) {
+		return fn(value.String()), nil
+	}
+}
+
+// FloatEncoder returns a RecordEncoder for numeric parameters.
+func FloatEncoder(fn func(float64) Record) RecordEncoder {
+	return func(value Value) (Record, error) {
+		return fn(value.Float64()), nil
+	}
+}
+
+// UintEncoder returns a RecordEncoder for unsigned integer parameters,
+// failing with ErrValueRange for values that are not a uint16.
+func UintEncoder(fn func(uint16) Record) RecordEncoder {
+	return func(value Value) (Record, error) {
+		u, err := uint16Value(value)
+		if err != nil {
+			return nil, err
+		}
+		return fn(u), nil
+	}
+}
+
+// BoolEncoder returns a RecordEncoder for on/off parameters, where any non
+// zero value is on.
+func BoolEncoder(fn func(bool) Record) RecordEncoder {
+	return func(value Value) (Record, error) {
+		return fn(value.Float64() != 0), nil
+	}
+}
+
+// StringEncoder returns a RecordEncoder for text parameters.
+func StringEncoder(fn func(string) Record) RecordEncoder {
+	return func(value Value) (Record, error) {
+		return fn(value.String()), nil
+	}
+}
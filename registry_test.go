@@ -0,0 +1,69 @@
+package ener314
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegisterRecord(t *testing.T) {
+	RegisterRecord(0x50, FloatDecoder(func(v float64) Record { return Humidity{v} }), nil)
+	RegisterProductRecord(0x7f, 0x01, 0x50, FloatDecoder(func(v float64) Record { return LightLevel{v} }), FloatEncoder(func(v float64) Record { return LightLevel{v} }))
+	t.Cleanup(func() {
+		UnregisterRecord(0x50)
+		UnregisterProductRecord(0x7f, 0x01, 0x50)
+	})
+
+	record, err := decodeRecord(energenieManuId, eTRVProdId, 0x50, 0x01, []byte{0x20})
+	assert.NoError(t, err)
+	assert.Equal(t, Humidity{32}, record)
+
+	record, err = decodeRecord(0x7f, 0x01, 0x50, 0x01, []byte{0x20})
+	assert.NoError(t, err)
+	assert.Equal(t, LightLevel{32}, record)
+
+	record, err = decodeRecord(0x7f, 0x01, 0x51, 0x01, []byte{0x20})
+	assert.NoError(t, err)
+	assert.Equal(t, UnhandledRecord{0x51, 0x01, []byte{0x20}}, record)
+
+	// empty values are unhandled, except for the parameters always rejected
+	record, err = decodeRecord(0x7f, 0x01, 0x50, 0x00, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, UnhandledRecord{0x50, 0x00, nil}, record)
+	_, err = decodeRecord(0x7f, 0x01, OT_TEMP_REPORT, 0x90, nil)
+	assert.Equal(t, ErrShortPacket, err)
+
+	record, err = NewRecord(0x7f, 0x01, 0x50, 12)
+	assert.NoError(t, err)
+	assert.Equal(t, LightLevel{12}, record)
+
+	_, err = NewRecord(energenieManuId, eTRVProdId, 0x50, 12)
+	assert.Equal(t, ErrNoEncoder, err)
+}
+
+func TestBuiltinEncoders(t *testing.T) {
+	record, err := NewRecord(energenieManuId, eTRVProdId, OT_TEMP_SET, 21.5)
+	assert.NoError(t, err)
+	assert.Equal(t, SetTemperature{21.5}, record)
+
+	record, err = NewRecord(energenieManuId, eTRVProdId, OT_SET_VALVE_STATE, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, SetValveState{VALVE_STATE_CLOSED}, record)
+
+	// values that do not fit the record are rejected rather than wrapped
+	_, err = NewRecord(energenieManuId, eTRVProdId, OT_SET_REPORTING_INTERVAL, 70000)
+	assert.Equal(t, ErrValueRange, err)
+	_, err = NewRecord(energenieManuId, eTRVProdId, OT_SET_REPORTING_INTERVAL, FloatValue(1.5))
+	assert.Equal(t, ErrValueRange, err)
+	_, err = decodeRecord(energenieManuId, eTRVProdId, OT_SET_REPORTING_INTERVAL, 0x03, []byte{0x01, 0x11, 0x70})
+	assert.Equal(t, ErrValueRange, err)
+}
+
+func TestUnregisterRecord(t *testing.T) {
+	RegisterRecord(0x51, FloatDecoder(func(v float64) Record { return Humidity{v} }), nil)
+	UnregisterRecord(0x51)
+
+	record, err := decodeRecord(energenieManuId, eTRVProdId, 0x51, 0x01, []byte{0x20})
+	assert.NoError(t, err)
+	assert.Equal(t, UnhandledRecord{0x51, 0x01, []byte{0x20}}, record)
+}
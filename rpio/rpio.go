@@ -299,11 +299,10 @@ func Open() (err error) {
 	}
 
 	// Convert mapped byte memory to unsafe []uint32 pointer, adjust length as needed
-	header := *(*reflect.SliceHeader)(unsafe.Pointer(&mem8))
-	header.Len /= (32 / 8) // (32 bit = 4 bytes)
-	header.Cap /= (32 / 8)
-
-	mem = *(*[]uint32)(unsafe.Pointer(&header))
+	header := (*reflect.SliceHeader)(unsafe.Pointer(&mem))
+	header.Data = uintptr(unsafe.Pointer(&mem8[0]))
+	header.Len = len(mem8) / (32 / 8) // (32 bit = 4 bytes)
+	header.Cap = cap(mem8) / (32 / 8)
 
 	return nil
 }
@@ -0,0 +1,108 @@
+package ener314
+
+import (
+	"sync"
+	"time"
+)
+
+// SensorEvent is a report from a motion or open/close sensor. Sensors repeat
+// their state periodically as a heartbeat, in which case Changed is false.
+type SensorEvent struct {
+	SensorId uint32
+	ProdId   byte
+	Active   bool // motion detected, or door/window open
+	Changed  bool
+	Time     time.Time
+}
+
+// SensorState is the last reported state of a motion or open/close sensor.
+type SensorState struct {
+	Active   bool      // motion detected, or door/window open
+	Changed  time.Time // when Active last changed
+	Battery  float64   // V, zero if never reported
+	LastSeen time.Time // time of the last report, including heartbeats
+}
+
+// Sensor is a MIHO032 Motion sensor or MIHO033 Open sensor.
+type Sensor struct {
+	SensorId uint32
+	ProdId   byte
+
+	dev   *Device
+	mu    sync.Mutex
+	state SensorState
+}
+
+// MotionSensor returns the handle for the MIHO032 Motion sensor with the given
+// id.
+func (d *Device) MotionSensor(sensorId uint32) *Sensor {
+	return d.sensor(motionProdId, sensorId)
+}
+
+// OpenSensor returns the handle for the MIHO033 Open sensor with the given id.
+func (d *Device) OpenSensor(sensorId uint32) *Sensor {
+	return d.sensor(openProdId, sensorId)
+}
+
+func (d *Device) sensor(prodId byte, sensorId uint32) *Sensor {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if s, ok := d.handles[handleKey{prodId, sensorId}].(*Sensor); ok {
+		return s
+	}
+	s := &Sensor{SensorId: sensorId, ProdId: prodId, dev: d}
+	d.addHandle(handleKey{prodId, sensorId}, s)
+	return s
+}
+
+// State returns the last reported state.
+func (s *Sensor) State() SensorState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state
+}
+
+// Alive reports whether the sensor has been heard from within the given
+// period, normally a little over its heartbeat interval.
+func (s *Sensor) Alive(period time.Duration, now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return !s.state.LastSeen.IsZero() && now.Sub(s.state.LastSeen) <= period
+}
+
+func (s *Sensor) update(msg *Message, now time.Time) {
+	s.mu.Lock()
+	var events []SensorEvent
+	for _, record := range msg.Records {
+		var active bool
+		switch t := record.(type) {
+		case MotionDetector:
+			active = t.Motion
+		case DoorSensor:
+			active = t.Open
+		case BatteryVoltage:
+			s.state.Battery = t.Value
+			continue
+		case Voltage:
+			s.state.Battery = t.Value
+			continue
+		default:
+			continue
+		}
+		changed := s.state.Changed.IsZero() || active != s.state.Active
+		if changed {
+			s.state.Active = active
+			s.state.Changed = now
+		}
+		events = append(events, SensorEvent{s.SensorId, s.ProdId, active, changed, now})
+	}
+	s.state.LastSeen = now
+	s.mu.Unlock()
+
+	for _, event := range events {
+		s.dev.publish(event)
+		if s.dev.OnSensorEvent != nil {
+			s.dev.OnSensorEvent(event)
+		}
+	}
+}
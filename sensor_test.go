@@ -0,0 +1,45 @@
+package ener314
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSensorEvents(t *testing.T) {
+	dev := NewDevice()
+	var events []SensorEvent
+	dev.OnSensorEvent = func(event SensorEvent) {
+		events = append(events, event)
+	}
+	sensor := dev.OpenSensor(0x000789)
+	assert.Same(t, sensor, dev.OpenSensor(0x000789))
+
+	start := time.Now()
+	assert.False(t, sensor.Alive(time.Minute, start))
+	for i, records := range [][]Record{
+		{DoorSensor{true}},
+		{DoorSensor{true}, BatteryVoltage{3.0}},
+		{DoorSensor{false}},
+	} {
+		sensor.update(&Message{
+			ManuId: energenieManuId, ProdId: openProdId, SensorId: 0x000789,
+			Records: records,
+		}, start.Add(time.Duration(i)*time.Second))
+	}
+
+	assert.Equal(t, []SensorEvent{
+		{0x000789, openProdId, true, true, start},
+		{0x000789, openProdId, true, false, start.Add(time.Second)},
+		{0x000789, openProdId, false, true, start.Add(2 * time.Second)},
+	}, events)
+	assert.Equal(t, SensorState{
+		Active:   false,
+		Changed:  start.Add(2 * time.Second),
+		Battery:  3.0,
+		LastSeen: start.Add(2 * time.Second),
+	}, sensor.State())
+	assert.True(t, sensor.Alive(time.Minute, start.Add(time.Minute)))
+	assert.False(t, sensor.Alive(time.Minute, start.Add(2*time.Minute)))
+}
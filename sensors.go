@@ -0,0 +1,330 @@
+package ener314
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// registrySaveInterval limits how often last seen times, RSSI and battery
+// readings are saved to the registry file.
+const registrySaveInterval = time.Minute
+
+// SensorInfo is what is known about a sensor: its product, the name and room
+// given to it, and when it was paired and last seen.
+type SensorInfo struct {
+	SensorId uint32
+	ManuId   byte
+	ProdId   byte
+	Name     string
+	Room     string
+	Paired   time.Time // zero if not paired
+	LastSeen time.Time
+	RSSI     float32 // dBm, of the last message received
+	Battery  float64 // V, zero if never reported
+}
+
+// sensorRegistry is the table of known sensors, optionally persisted to a
+// file.
+type sensorRegistry struct {
+	mu      sync.Mutex
+	sensors map[uint32]*SensorInfo
+	dirty   bool
+	saved   time.Time
+}
+
+func (r *sensorRegistry) get(sensorId uint32) (SensorInfo, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if s, ok := r.sensors[sensorId]; ok {
+		return *s, true
+	}
+	return SensorInfo{}, false
+}
+
+func (r *sensorRegistry) list() []SensorInfo {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ret := make([]SensorInfo, 0, len(r.sensors))
+	for _, s := range r.sensors {
+		ret = append(ret, *s)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].SensorId < ret[j].SensorId })
+	return ret
+}
+
+func (r *sensorRegistry) put(info SensorInfo) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.add(&info)
+	r.dirty = true
+}
+
+// add adds or replaces a sensor's entry. The lock must be held.
+func (r *sensorRegistry) add(s *SensorInfo) {
+	if r.sensors == nil {
+		r.sensors = map[uint32]*SensorInfo{}
+	}
+	r.sensors[s.SensorId] = s
+}
+
+func (r *sensorRegistry) remove(sensorId uint32) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.sensors, sensorId)
+	r.dirty = true
+}
+
+// modify applies fn to a sensor's entry, creating it if need be.
+func (r *sensorRegistry) modify(msg *Message, fn func(s *SensorInfo)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.sensors[msg.SensorId]
+	if !ok {
+		s = &SensorInfo{SensorId: msg.SensorId}
+		r.add(s)
+	}
+	s.ManuId, s.ProdId = msg.ManuId, msg.ProdId
+	fn(s)
+	r.dirty = true
+}
+
+// seen records a message received from a sensor.
+func (r *sensorRegistry) seen(msg *Message, rssi float32, now time.Time) {
+	r.modify(msg, func(s *SensorInfo) {
+		s.LastSeen = now
+		s.RSSI = rssi
+		for _, record := range msg.Records {
+			switch t := record.(type) {
+			case Voltage:
+				s.Battery = t.Value
+			case BatteryVoltage:
+				s.Battery = t.Value
+			}
+		}
+	})
+}
+
+func (r *sensorRegistry) write(w io.Writer) error {
+	b, err := json.MarshalIndent(r.list(), "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
+
+// read replaces the table with the sensors read from r.
+func (r *sensorRegistry) read(rd io.Reader) error {
+	var sensors []SensorInfo
+	if err := json.NewDecoder(rd).Decode(&sensors); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sensors = map[uint32]*SensorInfo{}
+	for i := range sensors {
+		r.sensors[sensors[i].SensorId] = &sensors[i]
+	}
+	return nil
+}
+
+// load reads the registry file, if it exists.
+func (r *sensorRegistry) load(filename string) error {
+	f, err := os.Open(filename)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return r.read(f)
+}
+
+// save writes the registry file, replacing it atomically.
+func (r *sensorRegistry) save(filename string, now time.Time) error {
+	f, err := ioutil.TempFile(filepath.Dir(filename), filepath.Base(filename)+".tmp")
+	if err != nil {
+		return err
+	}
+	err = r.write(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), filename)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	r.mu.Lock()
+	r.dirty = false
+	r.saved = now
+	r.mu.Unlock()
+	return nil
+}
+
+// saveDue reports whether there are changes not saved within interval.
+func (r *sensorRegistry) saveDue(interval time.Duration, now time.Time) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.dirty && now.Sub(r.saved) >= interval
+}
+
+type jsonSensorInfo struct {
+	SensorId string     `json:"sensor_id"`
+	ManuId   byte       `json:"manu_id"`
+	ProdId   byte       `json:"prod_id"`
+	Name     string     `json:"name,omitempty"`
+	Room     string     `json:"room,omitempty"`
+	Paired   *time.Time `json:"paired,omitempty"`
+	LastSeen *time.Time `json:"last_seen,omitempty"`
+	RSSI     float32    `json:"rssi,omitempty"`
+	Battery  float64    `json:"battery,omitempty"`
+}
+
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+func (s SensorInfo) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonSensorInfo{
+		SensorId: fmt.Sprintf("%06x", s.SensorId),
+		ManuId:   s.ManuId,
+		ProdId:   s.ProdId,
+		Name:     s.Name,
+		Room:     s.Room,
+		Paired:   optionalTime(s.Paired),
+		LastSeen: optionalTime(s.LastSeen),
+		RSSI:     s.RSSI,
+		Battery:  s.Battery,
+	})
+}
+
+func (s *SensorInfo) UnmarshalJSON(data []byte) error {
+	var js jsonSensorInfo
+	if err := json.Unmarshal(data, &js); err != nil {
+		return err
+	}
+	sensorId, err := strconv.ParseUint(js.SensorId, 16, 24)
+	if err != nil {
+		return fmt.Errorf("Invalid sensor_id %q", js.SensorId)
+	}
+	*s = SensorInfo{
+		SensorId: uint32(sensorId),
+		ManuId:   js.ManuId,
+		ProdId:   js.ProdId,
+		Name:     js.Name,
+		Room:     js.Room,
+		RSSI:     js.RSSI,
+		Battery:  js.Battery,
+	}
+	if js.Paired != nil {
+		s.Paired = *js.Paired
+	}
+	if js.LastSeen != nil {
+		s.LastSeen = *js.LastSeen
+	}
+	return nil
+}
+
+// KnownSensors returns the sensors in the registry.
+func (d *Device) KnownSensors() []SensorInfo {
+	return d.sensors.list()
+}
+
+// KnownSensor returns a sensor's registry entry.
+func (d *Device) KnownSensor(sensorId uint32) (SensorInfo, bool) {
+	return d.sensors.get(sensorId)
+}
+
+// UpdateSensor adds or replaces a sensor's registry entry, for example to
+// name it. Paired sensors are allowed to join, and others unpaired.
+func (d *Device) UpdateSensor(info SensorInfo) error {
+	d.sensors.put(info)
+	if info.Paired.IsZero() {
+		d.pairing.allow(info.SensorId, false)
+	} else {
+		d.pairing.remember(PairedDevice{info.SensorId, info.ManuId, info.ProdId, info.Paired})
+	}
+	return d.saveRegistry()
+}
+
+// RemoveSensor removes a sensor from the registry and unpairs it.
+func (d *Device) RemoveSensor(sensorId uint32) error {
+	d.sensors.remove(sensorId)
+	d.pairing.allow(sensorId, false)
+	return d.saveRegistry()
+}
+
+// ExportSensors writes the registry as JSON.
+func (d *Device) ExportSensors(w io.Writer) error {
+	return d.sensors.write(w)
+}
+
+// ImportSensors replaces the registry with one written by ExportSensors,
+// and the paired sensors with those paired in it.
+func (d *Device) ImportSensors(r io.Reader) error {
+	if err := d.sensors.read(r); err != nil {
+		return err
+	}
+	d.pairing.reset(d.pairedSensors())
+	return d.saveRegistry()
+}
+
+// LoadRegistry loads RegistryFile, if set and it exists. It is called by
+// Start.
+func (d *Device) LoadRegistry() error {
+	if d.RegistryFile == "" {
+		return nil
+	}
+	if err := d.sensors.load(d.RegistryFile); err != nil {
+		return err
+	}
+	d.rememberPaired()
+	return nil
+}
+
+// rememberPaired allows the paired sensors in the registry to join.
+func (d *Device) rememberPaired() {
+	for _, pd := range d.pairedSensors() {
+		d.pairing.remember(pd)
+	}
+}
+
+// pairedSensors are the sensors paired in the registry.
+func (d *Device) pairedSensors() []PairedDevice {
+	var ret []PairedDevice
+	for _, s := range d.sensors.list() {
+		if !s.Paired.IsZero() {
+			ret = append(ret, PairedDevice{s.SensorId, s.ManuId, s.ProdId, s.Paired})
+		}
+	}
+	return ret
+}
+
+// saveRegistryLogged saves the registry file, logging any error.
+func (d *Device) saveRegistryLogged() {
+	if err := d.saveRegistry(); err != nil {
+		d.log(LOG_ERROR, "Saving registry failed", "file", d.RegistryFile, "error", err)
+	}
+}
+
+func (d *Device) saveRegistry() error {
+	if d.RegistryFile == "" {
+		return nil
+	}
+	return d.sensors.save(d.RegistryFile, time.Now())
+}
@@ -0,0 +1,94 @@
+package ener314
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSensorRegistry(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ener314")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	dev := NewDevice()
+	dev.RegistryFile = filepath.Join(dir, "sensors.json")
+	assert.NoError(t, dev.LoadRegistry())
+	assert.Empty(t, dev.KnownSensors())
+
+	paired := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	assert.NoError(t, dev.UpdateSensor(SensorInfo{
+		SensorId: 0x00097f, ManuId: energenieManuId, ProdId: eTRVProdId,
+		Name: "Radiator", Room: "Kitchen", Paired: paired,
+	}))
+	assert.True(t, dev.JoinAllowed(0x00097f))
+
+	seen := paired.Add(time.Hour)
+	dev.sensors.seen(&Message{
+		ManuId: energenieManuId, ProdId: eTRVProdId, SensorId: 0x00097f,
+		Records: []Record{Voltage{3.1}},
+	}, -70.5, seen)
+	assert.NoError(t, dev.saveRegistry())
+
+	reloaded := NewDevice()
+	reloaded.RegistryFile = dev.RegistryFile
+	assert.NoError(t, reloaded.LoadRegistry())
+	expected := SensorInfo{
+		SensorId: 0x00097f, ManuId: energenieManuId, ProdId: eTRVProdId,
+		Name: "Radiator", Room: "Kitchen", Paired: paired,
+		LastSeen: seen, RSSI: -70.5, Battery: 3.1,
+	}
+	info, ok := reloaded.KnownSensor(0x00097f)
+	assert.True(t, ok)
+	assert.Equal(t, expected, info)
+	assert.True(t, reloaded.JoinAllowed(0x00097f))
+	assert.Equal(t, []PairedDevice{{0x00097f, energenieManuId, eTRVProdId, paired}}, reloaded.PairedDevices())
+
+	var buf bytes.Buffer
+	assert.NoError(t, reloaded.ExportSensors(&buf))
+	imported := NewDevice()
+	assert.NoError(t, imported.ImportSensors(&buf))
+	assert.Equal(t, []SensorInfo{expected}, imported.KnownSensors())
+
+	assert.NoError(t, reloaded.RemoveSensor(0x00097f))
+	assert.Empty(t, reloaded.KnownSensors())
+	assert.False(t, reloaded.JoinAllowed(0x00097f))
+}
+
+func TestImportSensorsUnpairs(t *testing.T) {
+	dev := NewDevice()
+	paired := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	assert.NoError(t, dev.UpdateSensor(SensorInfo{SensorId: 9, Paired: paired}))
+	assert.True(t, dev.JoinAllowed(9))
+
+	// importing replaces the paired sensors along with the registry
+	assert.NoError(t, dev.ImportSensors(bytes.NewBufferString("[]")))
+	assert.Empty(t, dev.KnownSensors())
+	assert.False(t, dev.JoinAllowed(9))
+	assert.Empty(t, dev.PairedDevices())
+
+	assert.NoError(t, dev.UpdateSensor(SensorInfo{SensorId: 9, Paired: paired}))
+	assert.NoError(t, dev.UpdateSensor(SensorInfo{SensorId: 9, Name: "Hall"}))
+	assert.False(t, dev.JoinAllowed(9))
+}
+
+func TestValidateCommand(t *testing.T) {
+	dev := NewDevice()
+	assert.NoError(t, dev.validateCommand(0x00097f, ActuateSwitch{true}))
+
+	dev.UpdateSensor(SensorInfo{SensorId: 0x00097f, ManuId: energenieManuId, ProdId: eTRVProdId})
+	assert.NoError(t, dev.validateCommand(0x00097f, Temperature{21}))
+	assert.NoError(t, dev.validateCommand(0x00097f, Voltage{}))
+	assert.EqualError(t, dev.validateCommand(0x00097f, Temperature{31}), "TARGET_TEMPERATURE out of range: 0 < 31 < 30")
+	assert.Equal(t, &UnsupportedCommandError{"MIHO013 eTRV", OT_ACTUATE_SW}, dev.validateCommand(0x00097f, ActuateSwitch{true}))
+	assert.EqualError(t, dev.validateCommand(0x00097f, ActuateSwitch{true}), "MIHO013 eTRV does not accept SWITCH")
+
+	_, err := dev.Queue(0x00097f, ActuateSwitch{true})
+	assert.Error(t, err)
+	assert.Empty(t, dev.QueuedCommands(0x00097f))
+}
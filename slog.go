@@ -0,0 +1,35 @@
+//go:build go1.21
+// +build go1.21
+
+package ener314
+
+import (
+	"context"
+	"log/slog"
+)
+
+// SlogLogger adapts a log/slog Logger, logging LOG_TRACE at slog.LevelDebug.
+type SlogLogger struct {
+	Logger *slog.Logger
+}
+
+// NewSlogLogger returns a Logger logging to l.
+func NewSlogLogger(l *slog.Logger) SlogLogger {
+	return SlogLogger{l}
+}
+
+func (s SlogLogger) Log(level int, msg string, fields ...interface{}) {
+	s.Logger.Log(context.Background(), slogLevel(level), msg, fields...)
+}
+
+func slogLevel(level int) slog.Level {
+	switch {
+	case level <= LOG_TRACE:
+		return slog.LevelDebug
+	case level == LOG_INFO:
+		return slog.LevelInfo
+	case level == LOG_WARN:
+		return slog.LevelWarn
+	}
+	return slog.LevelError
+}
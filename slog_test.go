@@ -0,0 +1,31 @@
+//go:build go1.21
+// +build go1.21
+
+package ener314
+
+import (
+	"bytes"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlogLogger(t *testing.T) {
+	var buf bytes.Buffer
+	handler := slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})
+	logger := NewSlogLogger(slog.New(handler))
+
+	logger.Log(LOG_TRACE, "Sent", "dir", "tx", "sensor", "00097f")
+	logger.Log(LOG_WARN, "Replayed pip", "pip", 4660)
+	assert.Equal(t, "level=DEBUG msg=Sent dir=tx sensor=00097f\n"+
+		"level=WARN msg=\"Replayed pip\" pip=4660\n", buf.String())
+}
@@ -0,0 +1,111 @@
+package ener314
+
+import (
+	"errors"
+	"time"
+)
+
+// SensorStats are the frames received from one sensor.
+type SensorStats struct {
+	Packets  uint64 // including duplicates
+	LastRSSI float32
+	LastSeen time.Time
+}
+
+// Stats are counters of radio and protocol activity, see Device.Stats.
+type Stats struct {
+	FramesReceived  uint64
+	CRCFailures     uint64
+	ShortPackets    uint64 // too short for their header or records
+	DecodeErrors    uint64 // other decode failures, such as unsupported lengths
+	UnknownProducts uint64 // frames from products not in the catalogue
+	Duplicates      uint64
+	Replays         uint64
+	FramesSent      uint64
+	SendErrors      uint64 // transmit failures, not counting ErrNotStarted
+	RadioResets     uint64
+	EventsDropped   uint64 // not delivered to subscribers not keeping up
+	Sensors         map[uint32]SensorStats
+}
+
+// deviceStats are the counters kept by a Device, guarded by its mu.
+type deviceStats struct {
+	framesReceived  uint64
+	crcFailures     uint64
+	shortPackets    uint64
+	decodeErrors    uint64
+	unknownProducts uint64
+	framesSent      uint64
+	sendErrors      uint64
+	radioResets     uint64
+	sensors         map[uint32]*SensorStats
+}
+
+// count updates the counters with fn.
+func (d *Device) count(fn func(s *deviceStats)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	fn(&d.stats)
+}
+
+// countReceived counts a frame received and the outcome of decoding it.
+func (d *Device) countReceived(msg *Message, rssi float32, err error, now time.Time) {
+	d.count(func(s *deviceStats) {
+		s.framesReceived += 1
+		switch {
+		case err == nil:
+		case errors.Is(err, ErrCRCFail):
+			s.crcFailures += 1
+		case errors.Is(err, ErrShortPacket):
+			s.shortPackets += 1
+		default:
+			s.decodeErrors += 1
+		}
+		if msg == nil {
+			return
+		}
+		if _, ok := msg.Product(); !ok {
+			s.unknownProducts += 1
+		}
+		ss, ok := s.sensors[msg.SensorId]
+		if !ok {
+			if s.sensors == nil {
+				s.sensors = map[uint32]*SensorStats{}
+			}
+			ss = &SensorStats{}
+			s.sensors[msg.SensorId] = ss
+		}
+		ss.Packets += 1
+		ss.LastRSSI = rssi
+		ss.LastSeen = now
+	})
+}
+
+// Stats returns counters of radio and protocol activity since NewDevice.
+func (d *Device) Stats() Stats {
+	d.events.mu.Lock()
+	dropped := d.events.dropped
+	d.events.mu.Unlock()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	s := &d.stats
+	ret := Stats{
+		FramesReceived:  s.framesReceived,
+		CRCFailures:     s.crcFailures,
+		ShortPackets:    s.shortPackets,
+		DecodeErrors:    s.decodeErrors,
+		UnknownProducts: s.unknownProducts,
+		Duplicates:      d.duplicates.duplicates,
+		Replays:         d.duplicates.replays,
+		FramesSent:      s.framesSent,
+		SendErrors:      s.sendErrors,
+		RadioResets:     s.radioResets,
+		EventsDropped:   dropped,
+		Sensors:         map[uint32]SensorStats{},
+	}
+	for id, ss := range s.sensors {
+		ret.Sensors[id] = *ss
+	}
+	return ret
+}
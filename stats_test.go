@@ -0,0 +1,75 @@
+package ener314
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStats(t *testing.T) {
+	radio := &fakeRadio{}
+	dev := NewDevice()
+	dev.Radio = radio
+	assert.NoError(t, dev.Start())
+
+	good := temperatureFrame(0x000001, 1)
+	corrupt := append([]byte(nil), good...)
+	corrupt[len(corrupt)-1] ^= 0xff
+	radio.inject(good, good, corrupt, good[:4])
+	for i := 0; i < 4; i += 1 {
+		dev.Receive()
+	}
+	assert.NoError(t, dev.Identify(0x000002))
+
+	stats := dev.Stats()
+	assert.Equal(t, uint64(4), stats.FramesReceived)
+	assert.Equal(t, uint64(1), stats.CRCFailures)
+	assert.Equal(t, uint64(1), stats.ShortPackets)
+	assert.Equal(t, uint64(0), stats.DecodeErrors)
+	assert.Equal(t, uint64(1), stats.Duplicates)
+	assert.Equal(t, uint64(1), stats.FramesSent)
+	assert.Equal(t, uint64(0), stats.SendErrors)
+	assert.Equal(t, map[uint32]SensorStats{
+		0x000001: {Packets: 2, LastRSSI: -60, LastSeen: stats.Sensors[0x000001].LastSeen},
+	}, stats.Sensors)
+	assert.False(t, stats.Sensors[0x000001].LastSeen.IsZero())
+}
+
+// failingRadio is a fakeRadio that fails to transmit.
+type failingRadio struct {
+	fakeRadio
+}
+
+func (r *failingRadio) SendFrame(frame []byte) error {
+	return errors.New("Transmit failed")
+}
+
+func TestStatsSendError(t *testing.T) {
+	dev := NewDevice()
+	// not started is not a transmit failure
+	assert.True(t, errors.Is(dev.Identify(0x000002), ErrNotStarted))
+	assert.Equal(t, uint64(0), dev.Stats().SendErrors)
+
+	dev.Radio = &failingRadio{}
+	assert.EqualError(t, dev.Identify(0x000002), "Sending to 000002: Transmit failed")
+	stats := dev.Stats()
+	assert.Equal(t, uint64(0), stats.FramesSent)
+	assert.Equal(t, uint64(1), stats.SendErrors)
+}
+
+func TestStatsUnsupportedLength(t *testing.T) {
+	radio := &fakeRadio{}
+	dev := NewDevice()
+	dev.Radio = radio
+	message := &Message{
+		ManuId: energenieManuId, ProdId: eTRVProdId, SensorId: 0x000001,
+		Records: []Record{NewUnhandledRecord(OT_TEMP_REPORT, Value{ENC_IEEE, []byte{1, 2, 3}})},
+	}
+	radio.inject(Encode(message, NewSequencePipSource(1)))
+	dev.Receive()
+
+	stats := dev.Stats()
+	assert.Equal(t, uint64(0), stats.ShortPackets)
+	assert.Equal(t, uint64(1), stats.DecodeErrors)
+}
@@ -0,0 +1,317 @@
+package ener314
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// MaxValueLength is the longest value the 4 bit length field can describe.
+// Longer values, such as strings over 15 characters, cannot be sent.
+const MaxValueLength = 15
+
+var (
+	ErrValueTooLong  = errors.New("Value too long")
+	ErrNegativeValue = errors.New("Negative value with an unsigned encoding")
+)
+
+// Value is a parameter value as it appears on the wire: an encoding and the
+// encoded bytes.
+type Value struct {
+	Encoding byte
+	Raw      []byte
+}
+
+// DecodeValue copies a value from a packet given its type descriptor.
+func DecodeValue(typeDesc byte, value []byte) Value {
+	return Value{typeDesc >> 4, append([]byte(nil), value...)}
+}
+
+// NewValue encodes a Go value (string, []byte, bool, integer or float) with
+// the given encoding.
+func NewValue(encoding byte, x interface{}) (Value, error) {
+	var v Value
+	var err error
+	switch t := x.(type) {
+	case Value:
+		v = t
+	case string:
+		if encoding == ENC_CHARS {
+			v = StringValue(t)
+		} else {
+			var f float64
+			f, err = strconv.ParseFloat(t, 64)
+			if err != nil {
+				return Value{}, err
+			}
+			v, err = FixedValue(encoding, f)
+		}
+	case []byte:
+		v = BytesValue(encoding, t)
+	case bool:
+		var u uint64
+		if t {
+			u = 1
+		}
+		v = UintValue(u)
+		v.Encoding = encoding
+	case int:
+		v, err = integerValue(encoding, int64(t))
+	case int64:
+		v, err = integerValue(encoding, t)
+	case uint64:
+		if encoding == ENC_UINT || encoding == ENC_ENUM {
+			v = UintValue(t)
+			v.Encoding = encoding
+		} else {
+			v, err = FixedValue(encoding, float64(t))
+		}
+	case float32:
+		v, err = FixedValue(encoding, float64(t))
+	case float64:
+		v, err = FixedValue(encoding, t)
+	default:
+		return Value{}, fmt.Errorf("Unsupported value type %T", x)
+	}
+	if err != nil {
+		return Value{}, err
+	}
+	return v, v.Validate()
+}
+
+func integerValue(encoding byte, i int64) (Value, error) {
+	switch {
+	case encoding == ENC_SINT:
+		return IntValue(i), nil
+	case (encoding == ENC_UINT || encoding == ENC_ENUM) && i >= 0:
+		v := UintValue(uint64(i))
+		v.Encoding = encoding
+		return v, nil
+	}
+	return FixedValue(encoding, float64(i))
+}
+
+// StringValue is a character value.
+func StringValue(s string) Value {
+	return Value{ENC_CHARS, []byte(s)}
+}
+
+// BytesValue is a value of already encoded bytes.
+func BytesValue(encoding byte, b []byte) Value {
+	return Value{encoding, append([]byte(nil), b...)}
+}
+
+// UintValue is an unsigned integer value, in as few bytes as possible.
+func UintValue(u uint64) Value {
+	return Value{ENC_UINT, unsignedBytes(u)}
+}
+
+// IntValue is a signed integer value, in as few bytes as possible.
+func IntValue(i int64) Value {
+	if i < 0 {
+		return Value{ENC_SINT, signedBytes(uint64(-i), true)}
+	}
+	return Value{ENC_SINT, signedBytes(uint64(i), false)}
+}
+
+// FloatValue is an IEEE754 value, single precision where that is lossless.
+func FloatValue(f float64) Value {
+	length := 8
+	if float64(float32(f)) == f {
+		length = 4
+	}
+	v, _ := IEEEValue(f, length)
+	return v
+}
+
+// IEEEValue is an IEEE754 value of the given length: 4 for single or 8 for
+// double precision.
+func IEEEValue(f float64, length int) (Value, error) {
+	raw := make([]byte, length)
+	switch length {
+	case 4:
+		binary.BigEndian.PutUint32(raw, math.Float32bits(float32(f)))
+	case 8:
+		binary.BigEndian.PutUint64(raw, math.Float64bits(f))
+	default:
+		return Value{}, ErrUnsupportedLength
+	}
+	return Value{ENC_IEEE, raw}, nil
+}
+
+// FixedValue encodes f with any numeric encoding, as fixed point for the
+// fixed point encodings, truncating any fraction that does not fit. Negative
+// values cannot be encoded with the unsigned encodings.
+func FixedValue(encoding byte, f float64) (Value, error) {
+	switch encoding {
+	case ENC_UINT, ENC_UFPp4, ENC_UFPp8, ENC_UFPp12, ENC_UFPp16, ENC_UFPp20, ENC_UFPp24, ENC_ENUM: // Unsigned x.n
+		if f < 0 {
+			return Value{}, ErrNegativeValue
+		}
+		if encoding == ENC_ENUM {
+			return Value{encoding, unsignedBytes(uint64(f))}, nil
+		}
+		scaled := math.Trunc(f * float64(uint64(1)<<(4*uint(encoding))))
+		return Value{encoding, unsignedBytes(uint64(scaled))}, nil
+	case ENC_SINT, ENC_SFPp8, ENC_SFPp16, ENC_SFPp24: // Signed x.n
+		scaled := math.Trunc(math.Abs(f) * float64(uint64(1)<<(8*uint(encoding-ENC_SINT))))
+		return Value{encoding, signedBytes(uint64(scaled), f < 0)}, nil
+	case ENC_CHARS: // Characters
+		return StringValue(strconv.FormatFloat(f, 'f', -1, 64)), nil
+	case ENC_IEEE: // IEEE754-2008 floating point
+		return FloatValue(f), nil
+	}
+	return Value{encoding, nil}, nil
+}
+
+// unsignedBytes is u big endian in at least one byte.
+func unsignedBytes(u uint64) []byte {
+	n := 1
+	for n < 8 && u>>(uint(n)*8) != 0 {
+		n += 1
+	}
+	ret := make([]byte, n)
+	for i := n - 1; i >= 0; i -= 1 {
+		ret[i] = byte(u)
+		u >>= 8
+	}
+	return ret
+}
+
+// signedBytes is the magnitude big endian with the top bit as the sign.
+func signedBytes(magnitude uint64, negative bool) []byte {
+	ret := unsignedBytes(magnitude)
+	if ret[0]&0x80 != 0 {
+		ret = append([]byte{0}, ret...)
+	}
+	if negative {
+		ret[0] |= 0x80
+	}
+	return ret
+}
+
+// Validate checks the value fits in a record.
+func (v Value) Validate() error {
+	if len(v.Raw) > MaxValueLength {
+		return ErrValueTooLong
+	}
+	if v.Encoding == ENC_IEEE && len(v.Raw) != 4 && len(v.Raw) != 8 {
+		return ErrUnsupportedLength
+	}
+	return nil
+}
+
+// TypeDesc is the type descriptor byte for the value.
+func (v Value) TypeDesc() byte {
+	return v.Encoding<<4 + byte(len(v.Raw))&0x0f
+}
+
+// Bytes returns a copy of the encoded bytes.
+func (v Value) Bytes() []byte {
+	return append([]byte(nil), v.Raw...)
+}
+
+func (v Value) signed() bool {
+	return v.Encoding >= ENC_SINT && v.Encoding <= ENC_SFPp24
+}
+
+// mantissa is the number of fractional bits of a fixed point encoding.
+func (v Value) mantissa() uint {
+	switch {
+	case v.Encoding <= ENC_UFPp24:
+		return 4 * uint(v.Encoding)
+	case v.signed():
+		return 8 * uint(v.Encoding-ENC_SINT)
+	}
+	return 0
+}
+
+// magnitude returns the integer on the wire, without any sign bit.
+func (v Value) magnitude() (uint64, bool) {
+	var ret uint64
+	negative := false
+	for i, b := range v.Raw {
+		if i == 0 && v.signed() && b&0x80 != 0 {
+			b &= 0x7f
+			negative = true
+		}
+		ret = ret<<8 + uint64(b)
+	}
+	return ret, negative
+}
+
+// Float64 is the numeric value. Characters are parsed as a number.
+func (v Value) Float64() float64 {
+	switch {
+	case v.Encoding <= ENC_UFPp24 || v.signed() || v.Encoding == ENC_ENUM:
+		var ret float64
+		negative := false
+		for i, b := range v.Raw {
+			if i == 0 && v.signed() && b&0x80 != 0 {
+				b &= 0x7f
+				negative = true
+			}
+			ret = ret*256 + float64(b)
+		}
+		if negative {
+			ret = -ret
+		}
+		return ret / float64(uint64(1)<<v.mantissa())
+	case v.Encoding == ENC_CHARS:
+		f64, _ := strconv.ParseFloat(string(v.Raw), 32)
+		return f64
+	case v.Encoding == ENC_IEEE:
+		f64, _ := decodeIEEE(v.Raw)
+		return f64
+	}
+	return 0
+}
+
+// Int64 is the value as a signed integer, truncating any fraction.
+func (v Value) Int64() int64 {
+	switch {
+	case v.Encoding == ENC_UINT || v.Encoding == ENC_ENUM || v.signed():
+		m, negative := v.magnitude()
+		m >>= v.mantissa()
+		if negative {
+			return -int64(m)
+		}
+		return int64(m)
+	}
+	return int64(v.Float64())
+}
+
+// Uint64 is the value as an unsigned integer, truncating any fraction.
+func (v Value) Uint64() uint64 {
+	switch {
+	case v.Encoding <= ENC_UFPp24 || v.Encoding == ENC_ENUM:
+		m, _ := v.magnitude()
+		return m >> v.mantissa()
+	case v.signed():
+		return uint64(v.Int64())
+	}
+	return uint64(v.Float64())
+}
+
+// Interface is the value as its most natural Go type: string for characters,
+// uint64 or int64 for integers, float64 for fixed point and floating point,
+// and []byte for reserved encodings.
+func (v Value) Interface() interface{} {
+	switch v.Encoding {
+	case ENC_CHARS:
+		return string(v.Raw)
+	case ENC_UINT, ENC_ENUM:
+		return v.Uint64()
+	case ENC_SINT:
+		return v.Int64()
+	case ENC_RESV1, ENC_RESV2:
+		return v.Bytes()
+	}
+	return v.Float64()
+}
+
+func (v Value) String() string {
+	return fmt.Sprint(v.Interface())
+}
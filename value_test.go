@@ -0,0 +1,114 @@
+package ener314
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var valueInterfaceTable = []struct {
+	value    Value
+	expected interface{}
+}{
+	{Value{ENC_CHARS, []byte("1.2.3")}, "1.2.3"},
+	{Value{ENC_UINT, []byte{0x01, 0x00}}, uint64(256)},
+	{Value{ENC_UINT, []byte{0x00, 0x20, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01}}, uint64(1<<53 + 1)},
+	{Value{ENC_SINT, []byte{0x92, 0x80}}, int64(-4736)},
+	{Value{ENC_ENUM, []byte{0x02}}, uint64(2)},
+	{Value{ENC_SFPp8, []byte{0x92, 0x80}}, -18.5},
+	{Value{ENC_UFPp4, []byte{0x12, 0x80}}, 296.0},
+	{Value{ENC_IEEE, []byte{0x42, 0x48, 0x00, 0x00}}, 50.0},
+	{Value{ENC_RESV1, []byte{0x34}}, []byte{0x34}},
+}
+
+func TestValueInterface(t *testing.T) {
+	for _, tt := range valueInterfaceTable {
+		assert.Equal(t, tt.expected, tt.value.Interface())
+	}
+}
+
+func TestValueDoesNotModifyPacket(t *testing.T) {
+	packet := []byte{0x92, 0x80}
+	assert.Equal(t, -18.5, decodeFloat64(0x92, packet))
+	assert.Equal(t, []byte{0x92, 0x80}, packet)
+}
+
+var newValueTable = []struct {
+	encoding byte
+	x        interface{}
+	expected Value
+}{
+	{ENC_CHARS, "v1.2", Value{ENC_CHARS, []byte("v1.2")}},
+	{ENC_UINT, true, Value{ENC_UINT, []byte{0x01}}},
+	{ENC_UINT, 300, Value{ENC_UINT, []byte{0x01, 0x2c}}},
+	{ENC_UINT, uint64(1<<53 + 1), Value{ENC_UINT, []byte{0x20, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01}}},
+	{ENC_SINT, -200, Value{ENC_SINT, []byte{0x80, 0xc8}}},
+	{ENC_SINT, 200, Value{ENC_SINT, []byte{0x00, 0xc8}}},
+	{ENC_SFPp8, -18.5, Value{ENC_SFPp8, []byte{0x92, 0x80}}},
+	{ENC_SFPp8, 21.3, Value{ENC_SFPp8, []byte{0x15, 0x4c}}}, // truncated
+	{ENC_UFPp8, "18.5", Value{ENC_UFPp8, []byte{0x12, 0x80}}},
+	{ENC_IEEE, 0.5, Value{ENC_IEEE, []byte{0x3f, 0x00, 0x00, 0x00}}},
+	{ENC_RESV1, []byte{1, 2}, Value{ENC_RESV1, []byte{1, 2}}},
+}
+
+func TestNewValue(t *testing.T) {
+	for _, tt := range newValueTable {
+		v, err := NewValue(tt.encoding, tt.x)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.expected, v)
+	}
+
+	_, err := NewValue(ENC_CHARS, strings.Repeat("x", 16))
+	assert.Equal(t, ErrValueTooLong, err)
+	_, err = NewValue(ENC_UINT, struct{}{})
+	assert.Error(t, err)
+	_, err = NewValue(ENC_UINT, -5)
+	assert.Equal(t, ErrNegativeValue, err)
+	_, err = NewValue(ENC_UFPp8, -1.5)
+	assert.Equal(t, ErrNegativeValue, err)
+	_, err = NewValue(ENC_UINT, "-5")
+	assert.Equal(t, ErrNegativeValue, err)
+	_, err = NewValue(ENC_UFPp8, "-1")
+	assert.Equal(t, ErrNegativeValue, err)
+}
+
+func TestDebugOutput(t *testing.T) {
+	message, err := NewMessageBuilder().Sensor(0x00097f).Param(OT_DEBUG_OUTPUT, "fw 1.4").Build()
+	assert.NoError(t, err)
+	decoded, err := decodePacket(encodeMessage(message))
+	assert.NoError(t, err)
+	assert.Equal(t, []Record{DebugOutput{"fw 1.4"}}, decoded.Records)
+
+	_, err = NewMessageBuilder().Record(DebugOutput{strings.Repeat("x", 16)}).Build()
+	assert.Equal(t, ErrValueTooLong, err)
+	_, err = NewMessageBuilder().Record(NewUnhandledRecord(0x5e, Value{ENC_IEEE, []byte{1, 2}})).Build()
+	assert.Equal(t, ErrUnsupportedLength, err)
+	_, err = NewMessageBuilder().Record(UnhandledRecord{0x50, 0x12, []byte{1}}, Identify{}).Build()
+	assert.Equal(t, ErrLengthMismatch, err)
+}
+
+var ieeeValueTable = []struct {
+	value    float64
+	length   int
+	expected []byte
+}{
+	{50, 4, []byte{0x42, 0x48, 0x00, 0x00}},
+	{50, 8, []byte{0x40, 0x49, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}},
+	{-0.75, 4, []byte{0xbf, 0x40, 0x00, 0x00}},
+}
+
+func TestIEEEValue(t *testing.T) {
+	for _, tt := range ieeeValueTable {
+		v, err := IEEEValue(tt.value, tt.length)
+		assert.NoError(t, err)
+		assert.Equal(t, Value{ENC_IEEE, tt.expected}, v)
+		assert.Equal(t, byte(0xf0+tt.length), v.TypeDesc())
+		assert.Equal(t, tt.value, v.Float64())
+	}
+
+	for _, length := range []int{0, 2, 3, 5, 16} {
+		_, err := IEEEValue(1, length)
+		assert.Equal(t, ErrUnsupportedLength, err)
+	}
+}